@@ -0,0 +1,114 @@
+// append.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Adding entries to an existing archive in place.  The new local entries
+// overwrite the old central directory, then Close writes a directory that
+// lists the old entries (their records copied as they were) and the new
+// ones.  Nothing already in the archive is read, recompressed or moved, so
+// appending to a big archive costs about what the new entries do.
+
+package zipfile
+
+import (
+	"errors"
+	"os"
+)
+
+var (
+	AppendSplitError   = errors.New("can't append to a split archive")
+	DuplicateNameError = errors.New("archive already has an entry with that name")
+)
+
+// OpenAppend opens the archive name for appending.  Entries made with Create
+// go after the existing ones, the archive comment is kept unless Comment is
+// changed, and Close writes the new central directory and closes the file.
+// Names already in the archive are refused with DuplicateNameError.
+//
+// The old directory is overwritten by the first new entry, so the archive
+// can't be read between then and a successful Close.  Copy it first if that
+// matters.
+func OpenAppend(name string) (*Writer, error) {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newAppendWriter(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func newAppendWriter(f *os.File) (*Writer, error) {
+	rz, err := NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		return nil, err
+	}
+	e, err := findEndCentDir(f)
+	if err != nil {
+		return nil, err
+	}
+	if e.diskNbr != 0 || e.dirDiskNbr != 0 {
+		return nil, AppendSplitError
+	}
+	dirStart := e.dirOffset + rz.shift
+	dir := make([]byte, e.dirSize)
+	if _, err = f.ReadAt(dir, dirStart); err != nil {
+		return nil, err
+	}
+	w := &Writer{
+		Comment: e.comment,
+		out:     &appendWriter{f: f, n: dirStart, shift: rz.shift},
+		names:   make(map[string]bool, len(filelist)),
+	}
+	for _, h := range filelist {
+		// CentralHeaders() already checked the records, just split them up
+		recLen := CentDirHdrSize + int(sixteenBit(dir[28:30])) +
+			int(sixteenBit(dir[30:32])) + int(sixteenBit(dir[32:34]))
+		w.dir = append(w.dir, &dirEntry{hdr: h, raw: dir[:recLen]})
+		w.names[h.Name] = true
+		dir = dir[recLen:]
+	}
+	if _, err = f.Seek(dirStart, 0); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// appendWriter is the volumeWriter for OpenAppend, it writes over the old
+// directory and cuts off whatever is left of it at the end
+type appendWriter struct {
+	f     *os.File
+	n     int64 // position in f
+	shift int64 // prefix the directory offsets don't allow for, see Prefix()
+}
+
+func (a *appendWriter) Write(p []byte) (int, error) {
+	n, err := a.f.Write(p)
+	a.n += int64(n)
+	return n, err
+}
+
+func (a *appendWriter) disk() int             { return 0 }
+func (a *appendWriter) offset() int64         { return a.n - a.shift }
+func (a *appendWriter) reserve(n int64) error { return nil }
+
+func (a *appendWriter) finish(ok bool) error {
+	var err error
+	if ok {
+		err = a.f.Truncate(a.n)
+	}
+	if cerr := a.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
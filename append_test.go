@@ -0,0 +1,130 @@
+// append_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Purpose: entries appended to an ordinary archive, and to self-extracting
+// ones with and without adjusted offsets, read back along with the old
+// entries, whose bytes aren't touched
+func TestAppend(t *testing.T) {
+	fmt.Printf("TestAppend start\n")
+	dir, err := ioutil.TempDir("", "append")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"unix.zip", "sfx.zip", "sfx-adjusted.zip"} {
+		orig, err := ioutil.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		archive := filepath.Join(dir, name)
+		if err = ioutil.WriteFile(archive, orig, 0644); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		rz, err := NewReader(bytes.NewReader(orig))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		before, err := rz.CentralHeaders()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		e, err := findEndCentDir(bytes.NewReader(orig))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		dirStart := e.offset - e.dirSize
+
+		w, err := OpenAppend(archive)
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", name, err)
+		}
+		if _, err = w.Create(&Header{Name: before[0].Name}); err != DuplicateNameError {
+			t.Errorf("%s: existing name: expected DuplicateNameError, got %v", name, err)
+		}
+		for _, h := range []*Header{{Name: "new/"}, {Name: "new/one.txt", Compress: ZIP_DEFLATED}} {
+			fw, err := w.Create(h)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if h.Typeflag != TypeDir {
+				io.WriteString(fw, "appended appended appended\n")
+			}
+		}
+		if err = w.Close(); err != nil {
+			t.Fatalf("%s: Unexpected error: %v", name, err)
+		}
+
+		grown, err := ioutil.ReadFile(archive)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !bytes.Equal(grown[:dirStart], orig[:dirStart]) {
+			t.Errorf("%s: existing entries were changed", name)
+		}
+		rz, err = NewReader(bytes.NewReader(grown))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		after, err := rz.CentralHeaders()
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", name, err)
+		}
+		if len(after) != len(before)+2 {
+			t.Fatalf("%s: %d entries after append, expected %d", name, len(after), len(before)+2)
+		}
+		for _, h := range after {
+			rdr, err := h.Open()
+			if err != nil {
+				t.Fatalf("%s: %s: Unexpected error: %v", name, h.Name, err)
+			}
+			data, err := ioutil.ReadAll(rdr)
+			if err != nil {
+				t.Fatalf("%s: %s: Unexpected error: %v", name, h.Name, err)
+			}
+			if h.Name == "new/one.txt" && string(data) != "appended appended appended\n" {
+				t.Errorf("%s: appended entry reads %q", name, data)
+			}
+		}
+		if ae, err := findEndCentDir(bytes.NewReader(grown)); err != nil || ae.comment != e.comment {
+			t.Errorf("%s: archive comment %q, %v", name, ae.comment, err)
+		}
+	}
+	// a failed Close still closes the file
+	archive := filepath.Join(dir, "unix.zip")
+	w, err := OpenAppend(archive)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	f := w.out.(*appendWriter).f
+	w.Comment = strings.Repeat("x", maxUint16+1)
+	if err = w.Close(); err != Zip64Error {
+		t.Errorf("long comment: expected Zip64Error, got %v", err)
+	}
+	if _, err = f.Stat(); err == nil {
+		t.Errorf("file still open after a failed Close")
+	}
+	if err = w.Close(); err != WriterClosedError {
+		t.Errorf("second Close: expected WriterClosedError, got %v", err)
+	}
+	if _, err := OpenAppend("testdata/split.zip"); err != AppendSplitError {
+		t.Errorf("split archive: expected AppendSplitError, got %v", err)
+	}
+	fmt.Printf("TestAppend fini\n")
+}
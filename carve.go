@@ -0,0 +1,388 @@
+// carve.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Carve looks for zip data inside something that isn't a zip file, a raw
+// disk image or a memory dump for instance.  It scans for the local header,
+// central directory and end of central directory signatures, works out
+// where whole archives start and end, and reports any local headers that
+// don't belong to an archive it could rebuild.  Every candidate gets a
+// confidence score because "PK\003\004" turns up by chance in big images.
+
+package zipfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"hash/crc32"
+	"io"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	carveChunk    = 1 << 20 // bytes scanned per read
+	carveMaxName  = 4096    // longer names are taken as noise
+	carveMaxCheck = 64 << 20
+)
+
+// CarvedEntry is a local header found by Carve
+type CarvedEntry struct {
+	Header     *Header // Open() reads from the image
+	Offset     int64   // of the local header in the image
+	Confidence float64 // 0 thru 1, 1 means the data decompressed and the CRC matched
+}
+
+// CarvedArchive is an archive Carve could put back together.  Reading
+// Start thru End of the image gives something a zip tool can open.
+type CarvedArchive struct {
+	Start      int64 // first byte of the archive
+	End        int64 // one past the last byte
+	EndCentDir int64 // offset of the EOCD record, -1 if none was found
+	Entries    []*CarvedEntry
+	Confidence float64
+}
+
+// CarveResult is everything Carve found, sorted by offset
+type CarveResult struct {
+	Archives []*CarvedArchive
+	Orphans  []*CarvedEntry // local headers outside of every archive
+}
+
+// carveSigs records where each signature turned up
+type carveSigs struct {
+	local, central, end []int64
+}
+
+// Carve scans the first size bytes of r.  Archives with an end of central
+// directory record are rebuilt from their directory; chains of local headers
+// that follow one another without a directory (a truncated archive) are
+// reported as archives with EndCentDir -1 and lower confidence; any local
+// header left over is an orphan.
+func Carve(r io.ReaderAt, size int64) (*CarveResult, error) {
+	sigs, err := scanSigs(r, size)
+	if err != nil {
+		return nil, err
+	}
+	img := io.NewSectionReader(r, 0, size)
+	res := new(CarveResult)
+	claimed := make(map[int64]bool) // local headers that belong to an archive
+	for _, off := range sigs.end {
+		if a := carveFromEnd(img, size, off, claimed); a != nil {
+			res.Archives = append(res.Archives, a)
+		}
+	}
+	var loose []*CarvedEntry
+	for _, off := range sigs.local {
+		if claimed[off] {
+			continue
+		}
+		if ce := carveLocal(img, size, off); ce != nil {
+			loose = append(loose, ce)
+		}
+	}
+	central := make(map[int64]bool, len(sigs.central))
+	for _, off := range sigs.central {
+		central[off] = true
+	}
+	res.Archives = append(res.Archives, chainLocals(img, loose, central, claimed)...)
+	for _, ce := range loose {
+		if !claimed[ce.Header.LocalOffset] {
+			res.Orphans = append(res.Orphans, ce)
+		}
+	}
+	sort.Sort(byStart(res.Archives))
+	return res, nil
+}
+
+// scanSigs finds every "PK" signature we care about.  Reads overlap by three
+// bytes so a signature split across two reads isn't missed.
+func scanSigs(r io.ReaderAt, size int64) (*carveSigs, error) {
+	sigs := new(carveSigs)
+	buf := make([]byte, carveChunk+3)
+	for pos := int64(0); pos < size; pos += carveChunk {
+		want := int64(len(buf))
+		if pos+want > size {
+			want = size - pos
+		}
+		n, err := r.ReadAt(buf[:want], pos)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		chunk := buf[:n]
+		for i := 0; i+4 <= len(chunk); {
+			j := bytes.Index(chunk[i:], []byte("PK"))
+			if j < 0 || i+j+4 > len(chunk) {
+				break
+			}
+			i += j
+			switch string(chunk[i : i+4]) {
+			case ZIP_LocalHdrSig:
+				sigs.local = append(sigs.local, pos+int64(i))
+			case ZIP_CentDirSig:
+				sigs.central = append(sigs.central, pos+int64(i))
+			case ZIP_EndCentDirSig:
+				sigs.end = append(sigs.end, pos+int64(i))
+			}
+			i++
+		}
+	}
+	return sigs, nil
+}
+
+// carveFromEnd tries to rebuild the archive whose EOCD record is at off
+func carveFromEnd(img *io.SectionReader, size, off int64, claimed map[int64]bool) *CarvedArchive {
+	rec := make([]byte, EndCentDirSize)
+	if _, err := img.ReadAt(rec, off); err != nil {
+		return nil
+	}
+	commentLen := int64(sixteenBit(rec[20:22]))
+	dirSize := int64(thirtyTwoBit(rec[12:16]))
+	dirOffset := int64(thirtyTwoBit(rec[16:20]))
+	records := int(sixteenBit(rec[10:12]))
+	end := off + EndCentDirSize + commentLen
+	dirStart := off - dirSize
+	start := dirStart - dirOffset
+	if end > size || dirStart < 0 || start < 0 {
+		return nil
+	}
+	// a reader on just this archive, so Header offsets come out right
+	section := io.NewSectionReader(img, start, end-start)
+	rz, err := NewReader(section)
+	if err != nil {
+		return nil
+	}
+	rz.SetLimits(Limits{})
+	filelist, err := rz.CentralHeaders()
+	if err != nil || len(filelist) == 0 && records > 0 {
+		return nil
+	}
+	a := &CarvedArchive{Start: start, End: end, EndCentDir: off}
+	found := 0.0
+	for _, hdr := range filelist {
+		ce := &CarvedEntry{Header: hdr, Offset: start + hdr.LocalOffset, Confidence: 0.5}
+		if _, err := hdr.DataOffset(); err == nil {
+			found++
+			ce.Confidence = 0.75
+			if verifyCarved(hdr) {
+				ce.Confidence = 1
+			}
+		}
+		claimed[start+hdr.LocalOffset] = true
+		a.Entries = append(a.Entries, ce)
+	}
+	// directory parsed cleanly, so start high and lose confidence for each
+	// local header that wasn't where the directory said it would be
+	a.Confidence = 0.5
+	if len(filelist) > 0 {
+		a.Confidence += 0.5 * found / float64(len(filelist))
+	}
+	if len(filelist) != records {
+		a.Confidence /= 2
+	}
+	return a
+}
+
+// carveLocal decodes a local header found at off, nil if it is nonsense
+func carveLocal(img *io.SectionReader, size, off int64) *CarvedEntry {
+	b := make([]byte, LocalHdrSize)
+	if _, err := img.ReadAt(b, off); err != nil {
+		return nil
+	}
+	nameLen := int64(sixteenBit(b[26:28]))
+	extraLen := int64(sixteenBit(b[28:30]))
+	if nameLen == 0 || nameLen > carveMaxName || off+LocalHdrSize+nameLen+extraLen > size {
+		return nil
+	}
+	name := make([]byte, nameLen)
+	if _, err := img.ReadAt(name, off+LocalHdrSize); err != nil {
+		return nil
+	}
+	hdr := &Header{
+		Name:        string(name),
+		Flags:       sixteenBit(b[6:8]),
+		Compress:    sixteenBit(b[8:10]),
+		StoredCrc32: thirtyTwoBit(b[14:18]),
+		SizeCompr:   int64(thirtyTwoBit(b[18:22])),
+		Size:        int64(thirtyTwoBit(b[22:26])),
+		Mtime:       makeGoDate(sixteenBit(b[12:14]), sixteenBit(b[10:12])),
+		LocalOffset: off,
+		Offset:      off + LocalHdrSize + nameLen + extraLen,
+		Hreader:     img,
+	}
+	hdr.dosDate, hdr.dosTime = sixteenBit(b[12:14]), sixteenBit(b[10:12])
+	hdr.Typeflag = hdr.typeflag()
+	ce := &CarvedEntry{Header: hdr, Offset: off, Confidence: 0.25}
+	if sixteenBit(b[4:6]) <= 63 && utf8.Valid(name) && printable(name) {
+		ce.Confidence = 0.5
+	}
+	if hdr.Flags&flagDataDesc == 0 && hdr.Offset+hdr.SizeCompr > size {
+		return ce // runs off the end of the image
+	}
+	switch {
+	case hdr.Flags&flagDataDesc == 0:
+		if verifyCarved(hdr) {
+			ce.Confidence = 1
+		}
+	case hdr.Compress == ZIP_DEFLATED:
+		if resolveDescriptor(img, hdr) {
+			ce.Confidence = 1
+		}
+	}
+	return ce
+}
+
+// resolveDescriptor fills in the sizes and CRC of a deflated entry that
+// keeps them in a data descriptor.  Deflate marks its own end, so inflating
+// the data shows where the descriptor is; false if what's found there
+// doesn't agree with what was inflated.
+func resolveDescriptor(img *io.SectionReader, h *Header) bool {
+	// countingReader is a ByteReader, so flate takes exactly what it needs
+	src := &countingReader{r: bufio.NewReader(io.NewSectionReader(img, h.Offset, carveMaxCheck))}
+	crc := crc32.NewIEEE()
+	size, err := io.Copy(crc, io.LimitReader(flate.NewReader(src), carveMaxCheck))
+	if err != nil || size == carveMaxCheck {
+		return false
+	}
+	desc := make([]byte, DataDescSize+4)
+	n, _ := img.ReadAt(desc, h.Offset+src.n)
+	desc = desc[:n]
+	if len(desc) >= 4 && string(desc[:4]) == ZIP_DataDescSig { // signature is optional
+		desc = desc[4:]
+	}
+	if len(desc) < DataDescSize || thirtyTwoBit(desc[0:4]) != crc.Sum32() ||
+		int64(thirtyTwoBit(desc[4:8])) != src.n || int64(thirtyTwoBit(desc[8:12])) != size {
+		return false
+	}
+	h.StoredCrc32, h.SizeCompr, h.Size = crc.Sum32(), src.n, size
+	return true
+}
+
+// chainLocals groups loose local headers that follow one after another,
+// each starting right where the previous one's data ended, into archives
+// whose EOCD record is missing.  If central directory records follow the
+// last entry they are taken into the archive too.  Members are added to
+// claimed.
+func chainLocals(img *io.SectionReader, loose []*CarvedEntry, central, claimed map[int64]bool) []*CarvedArchive {
+	byOffset := make(map[int64]*CarvedEntry, len(loose))
+	for _, ce := range loose {
+		byOffset[ce.Header.LocalOffset] = ce
+	}
+	var archives []*CarvedArchive
+	for _, ce := range loose {
+		off := ce.Header.LocalOffset
+		if claimed[off] {
+			continue
+		}
+		chain := []*CarvedEntry{ce}
+		for {
+			next := chainNext(img, chain[len(chain)-1].Header, byOffset)
+			if next == nil || claimed[next.Header.LocalOffset] {
+				break
+			}
+			chain = append(chain, next)
+		}
+		last := chain[len(chain)-1].Header
+		dirEnd, records := centralRun(img, entryEnd(img, last), central)
+		if len(chain) < 2 && records == 0 {
+			continue // leave it as an orphan
+		}
+		a := &CarvedArchive{Start: off, End: dirEnd, EndCentDir: -1}
+		total := 0.0
+		for _, member := range chain {
+			claimed[member.Header.LocalOffset] = true
+			total += member.Confidence
+			a.Entries = append(a.Entries, member)
+		}
+		// no EOCD to back it up, a directory that matches the entries helps
+		a.Confidence = 0.6 * total / float64(len(chain))
+		if records == len(chain) {
+			a.Confidence += 0.2
+		}
+		archives = append(archives, a)
+	}
+	return archives
+}
+
+// chainNext finds the entry that starts right after h
+func chainNext(img *io.SectionReader, h *Header, byOffset map[int64]*CarvedEntry) *CarvedEntry {
+	return byOffset[entryEnd(img, h)]
+}
+
+// entryEnd is where h's data, and data descriptor if it has one, ends
+func entryEnd(img *io.SectionReader, h *Header) int64 {
+	end := h.Offset + h.SizeCompr
+	if h.Flags&flagDataDesc == 0 {
+		return end
+	}
+	sig := make([]byte, 4)
+	if _, err := img.ReadAt(sig, end); err == nil && string(sig) == ZIP_DataDescSig {
+		return end + 4 + DataDescSize
+	}
+	return end + DataDescSize
+}
+
+// centralRun walks the central directory records that start at off, as
+// long as each one is followed by another.  Returns where the run ends and
+// how many records it held.
+func centralRun(img *io.SectionReader, off int64, central map[int64]bool) (int64, int) {
+	records := 0
+	rec := make([]byte, CentDirHdrSize)
+	for central[off] {
+		if _, err := img.ReadAt(rec, off); err != nil {
+			break
+		}
+		off += CentDirHdrSize + int64(sixteenBit(rec[28:30])) +
+			int64(sixteenBit(rec[30:32])) + int64(sixteenBit(rec[32:34]))
+		records++
+	}
+	return off, records
+}
+
+// verifyCarved decompresses the entry and checks its CRC, without the
+// Paranoid side effects of Open().  The sizes must be known, from a
+// directory or a local header without a data descriptor.
+func verifyCarved(h *Header) bool {
+	if h.Size > carveMaxCheck || h.SizeCompr > carveMaxCheck {
+		return false // too big to bother with
+	}
+	if _, err := h.DataOffset(); err != nil {
+		return false
+	}
+	if _, err := h.Hreader.Seek(h.Offset, 0); err != nil {
+		return false
+	}
+	var rdr io.Reader = io.LimitReader(h.Hreader, h.SizeCompr)
+	if h.Compress == ZIP_STORED {
+		if h.Size != h.SizeCompr {
+			return false
+		}
+	} else if dcomp := decompressor(h.Compress); dcomp != nil {
+		rdr = dcomp(rdr)
+	} else {
+		return false
+	}
+	crc := crc32.NewIEEE()
+	n, err := io.Copy(crc, io.LimitReader(rdr, h.Size+1))
+	return err == nil && n == h.Size && crc.Sum32() == h.StoredCrc32
+}
+
+// printable rejects names with control characters, real archives don't have them
+func printable(name []byte) bool {
+	for _, c := range name {
+		if c < ' ' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+type byStart []*CarvedArchive
+
+func (a byStart) Len() int           { return len(a) }
+func (a byStart) Less(i, j int) bool { return a[i].Start < a[j].Start }
+func (a byStart) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
@@ -0,0 +1,172 @@
+// carve_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// carveImage builds a fake disk image: noise, a whole archive, an archive
+// that lost its EOCD record and a lone entry with no directory at all.
+// Returns the image and where each piece starts.
+func carveImage(t *testing.T) ([]byte, []int64) {
+	var img bytes.Buffer
+	var starts []int64
+	noise := bytes.Repeat([]byte("noise PK\003\004\000\000 "), 20)
+	img.Write(noise)
+
+	whole, err := ioutil.ReadFile("testdata/stuf.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	starts = append(starts, int64(img.Len()))
+	img.Write(whole)
+	img.Write(make([]byte, 100))
+
+	unix, err := ioutil.ReadFile("testdata/unix.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	starts = append(starts, int64(img.Len()))
+	img.Write(unix[:len(unix)-EndCentDirSize])
+	img.Write(noise)
+
+	// just the local header and data of mini.zip's only entry
+	f, err := os.Open("testdata/mini.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	off, err := filelist[0].DataOffset()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	mini, err := ioutil.ReadFile("testdata/mini.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	starts = append(starts, int64(img.Len()))
+	img.Write(mini[:off+filelist[0].SizeCompr])
+	img.Write(noise)
+	return img.Bytes(), starts
+}
+
+// Purpose: Carve finds a whole archive, a truncated one and an orphaned
+// entry in a disk image, and the entries it reports can be read
+func TestCarve(t *testing.T) {
+	fmt.Printf("TestCarve start\n")
+	img, starts := carveImage(t)
+	res, err := Carve(bytes.NewReader(img), int64(len(img)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(res.Archives) != 2 {
+		t.Fatalf("expected 2 archives, got %d", len(res.Archives))
+	}
+
+	whole := res.Archives[0]
+	if whole.Start != starts[0] || whole.EndCentDir < 0 {
+		t.Errorf("whole archive at %d (EOCD %d), expected %d", whole.Start, whole.EndCentDir, starts[0])
+	}
+	if whole.Confidence != 1 || len(whole.Entries) != 1 || whole.Entries[0].Confidence != 1 {
+		t.Errorf("whole archive: confidence %v with %d entries", whole.Confidence, len(whole.Entries))
+	}
+	if off := whole.Entries[0].Offset; off != starts[0] {
+		t.Errorf("whole archive's entry at %d, expected %d", off, starts[0])
+	}
+	// the byte range is a usable archive on its own
+	rz, err := NewReader(bytes.NewReader(img[whole.Start:whole.End]))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if filelist, err := rz.CentralHeaders(); err != nil || len(filelist) != 1 {
+		t.Errorf("carved archive doesn't read back: %d entries, %v", len(filelist), err)
+	}
+
+	truncated := res.Archives[1]
+	if truncated.Start != starts[1] || truncated.EndCentDir != -1 || len(truncated.Entries) != 5 {
+		t.Errorf("truncated archive at %d (EOCD %d) with %d entries, expected %d",
+			truncated.Start, truncated.EndCentDir, len(truncated.Entries), starts[1])
+	}
+	unix, _ := ioutil.ReadFile("testdata/unix.zip")
+	if want := starts[1] + int64(len(unix)-EndCentDirSize); truncated.End != want {
+		t.Errorf("truncated archive ends at %d, expected %d", truncated.End, want)
+	}
+	if truncated.Confidence <= 0.5 || truncated.Confidence >= whole.Confidence {
+		t.Errorf("truncated archive confidence %v", truncated.Confidence)
+	}
+	if name := truncated.Entries[1].Header.Name; name != "unix/readme.txt" {
+		t.Errorf("second entry is %q", name)
+	}
+
+	if len(res.Orphans) != 1 {
+		t.Fatalf("expected 1 orphan, got %d", len(res.Orphans))
+	}
+	orphan := res.Orphans[0]
+	if orphan.Header.LocalOffset != starts[2] || orphan.Header.Name != "mini.txt" || orphan.Confidence != 1 {
+		t.Errorf("orphan %q at %d confidence %v", orphan.Header.Name, orphan.Header.LocalOffset, orphan.Confidence)
+	}
+	contents, err := orphan.Header.Open()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want, _ := ioutil.ReadFile("testdata/mini.txt")
+	if got, _ := ioutil.ReadAll(contents); !bytes.Equal(got, want) {
+		t.Errorf("orphan contents %q, expected %q", got, want)
+	}
+	fmt.Printf("TestCarve fini\n")
+}
+
+// Purpose: entries with data descriptors are measured by inflating them, so
+// they chain together and verify without a central directory
+func TestCarveDescriptor(t *testing.T) {
+	fmt.Printf("TestCarveDescriptor start\n")
+	descr, err := ioutil.ReadFile("testdata/descr.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// chop off the directory, leaving just the local entries
+	img := descr[:bytes.Index(descr, []byte(ZIP_CentDirSig))]
+	res, err := Carve(bytes.NewReader(img), int64(len(img)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(res.Archives) != 1 || len(res.Orphans) != 0 {
+		t.Fatalf("expected 1 archive and no orphans, got %d and %d", len(res.Archives), len(res.Orphans))
+	}
+	a := res.Archives[0]
+	if len(a.Entries) != 2 || a.End != int64(len(img)) {
+		t.Errorf("archive has %d entries and ends at %d", len(a.Entries), a.End)
+	}
+	for _, ce := range a.Entries {
+		if ce.Confidence != 1 {
+			t.Errorf("%s: confidence %v", ce.Header.Name, ce.Confidence)
+		}
+	}
+	rdr, err := a.Entries[0].Header.Open()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want, _ := ioutil.ReadFile("testdata/stuf.txt")
+	if got, _ := ioutil.ReadAll(rdr); !bytes.Equal(got, want) {
+		t.Errorf("got %q, expected %q", got, want)
+	}
+	fmt.Printf("TestCarveDescriptor fini\n")
+}
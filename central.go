@@ -0,0 +1,430 @@
+// central.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Central directory support.  Headers() builds its list by walking the local
+// headers, which is what we want for damaged media, but some information only
+// lives in the central directory at the end of the archive.  The most useful
+// of these are the version-made-by and external attribute fields which hold
+// the unix permissions, file type and symlink flag that Info-ZIP records.
+
+package zipfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	ZIP_EndCentDirSig = "PK\005\006"
+	CentDirHdrSize    = 46
+	EndCentDirSize    = 22
+	maxCommentLen     = 1<<16 - 1
+)
+
+// values for Typeflag, borrowed from archive/tar so they read the same way
+const (
+	TypeReg     = '0'
+	TypeSymlink = '2'
+	TypeChar    = '3'
+	TypeBlock   = '4'
+	TypeDir     = '5'
+	TypeFifo    = '6'
+	TypeSocket  = 's' // not in tar, but unix zips can hold them
+)
+
+// host system that created the entry, upper byte of version-made-by
+const (
+	creatorFAT    = 0
+	creatorUnix   = 3
+	creatorNTFS   = 11
+	creatorVFAT   = 14
+	creatorMacOSX = 19
+)
+
+// unix st_mode bits as stored in the upper 16 bits of the external attributes
+const (
+	s_IFMT   = 0xf000
+	s_IFSOCK = 0xc000
+	s_IFLNK  = 0xa000
+	s_IFREG  = 0x8000
+	s_IFBLK  = 0x6000
+	s_IFDIR  = 0x4000
+	s_IFCHR  = 0x2000
+	s_IFIFO  = 0x1000
+	s_ISUID  = 0x800
+	s_ISGID  = 0x400
+	s_ISVTX  = 0x200
+
+	msdosDir      = 0x10
+	msdosReadOnly = 0x01
+)
+
+var (
+	NoEndCentDirError  = errors.New("End of Central Directory record not found")
+	CentDirSigError    = errors.New("Bad Central Dir Sig (invalid magic number)")
+	BadEndCentDirError = errors.New("End of Central Directory record is inconsistent")
+	NotSymlinkError    = errors.New("entry is not a symbolic link")
+)
+
+// endCentDir holds the fields we use from the End of Central Directory record
+type endCentDir struct {
+	offset     int64 // where the record itself was found
+	diskNbr    uint16
+	dirDiskNbr uint16
+	dirRecords uint16
+	dirSize    int64
+	dirOffset  int64
+	comment    string
+}
+
+// findEndCentDir looks backward from the end of the archive for the EOCD record.
+// The record is 22 bytes plus a variable length comment so we only need to
+// look at the last 64K or so.  Most archives have no comment, so try a small
+// read first, it matters when the archive is on the other end of a network.
+func findEndCentDir(r io.ReadSeeker) (*endCentDir, error) {
+	size, err := r.Seek(0, 2)
+	if err != nil {
+		return nil, err
+	}
+	if size < EndCentDirSize {
+		return nil, NoEndCentDirError
+	}
+	for _, bufLen := range []int64{1024, EndCentDirSize + maxCommentLen} {
+		if bufLen > size {
+			bufLen = size
+		}
+		e, err := searchEndCentDir(r, size, bufLen)
+		if err != NoEndCentDirError || bufLen == size {
+			return e, err
+		}
+	}
+	return nil, NoEndCentDirError
+}
+
+// searchEndCentDir checks the last bufLen bytes of the archive for the EOCD record
+func searchEndCentDir(r io.ReadSeeker, size, bufLen int64) (*endCentDir, error) {
+	var err error
+	buf := make([]byte, bufLen)
+	if _, err = r.Seek(size-bufLen, 0); err != nil {
+		return nil, err
+	}
+	if _, err = io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	for i := len(buf) - EndCentDirSize; i >= 0; i-- {
+		if string(buf[i:i+4]) != ZIP_EndCentDirSig {
+			continue
+		}
+		rec := buf[i:]
+		commentLen := int(sixteenBit(rec[20:22]))
+		if EndCentDirSize+commentLen > len(rec) {
+			continue // signature bytes inside someone's data, keep looking
+		}
+		e := new(endCentDir)
+		e.offset = size - bufLen + int64(i)
+		e.diskNbr = sixteenBit(rec[4:6])
+		e.dirDiskNbr = sixteenBit(rec[6:8])
+		e.dirRecords = sixteenBit(rec[10:12])
+		e.dirSize = int64(thirtyTwoBit(rec[12:16]))
+		e.dirOffset = int64(thirtyTwoBit(rec[16:20]))
+		e.comment = string(rec[EndCentDirSize : EndCentDirSize+commentLen])
+		return e, nil
+	}
+	return nil, NoEndCentDirError
+}
+
+// CentralHeaders returns one header pointer for each entry listed in the
+// central directory.  Unlike Headers() the result includes the creator
+// version and external attributes, so Mode() can report unix permissions,
+// directories and symlinks.  Only the directory itself is read, Offset is
+// filled in later by Open() or DataOffset().
+func (r *ZipReader) CentralHeaders() ([]*Header, error) {
+	e, err := findEndCentDir(r.reader)
+	if err != nil {
+		return nil, err
+	}
+	if e.dirSize > e.offset {
+		return nil, BadEndCentDirError // claims a directory bigger than the archive
+	}
+	if err = r.countEntry(int(e.dirRecords)); err != nil {
+		return nil, err
+	}
+	if !r.split() {
+		// the directory ends where the EOCD record starts, any difference
+		// from where it claims to be is a prefix nobody allowed for
+		r.shift = e.offset - e.dirSize - e.dirOffset
+		if r.shift < 0 {
+			return nil, BadEndCentDirError
+		}
+	}
+	dirOffset, err := r.logical(e.dirDiskNbr, e.dirOffset)
+	if err != nil {
+		return nil, err
+	}
+	dir := make([]byte, e.dirSize)
+	if _, err = r.reader.Seek(dirOffset, 0); err != nil {
+		return nil, err
+	}
+	if _, err = io.ReadFull(r.reader, dir); err != nil {
+		return nil, err
+	}
+	Hdrs := make([]*Header, 0, e.dirRecords)
+	for len(dir) > 0 {
+		hdr, n, err := r.unpackCentralHeader(dir)
+		if err != nil {
+			return nil, err
+		}
+		if Verbose {
+			hdr.Dump()
+		}
+		Hdrs = append(Hdrs, hdr)
+		if err = r.countEntry(len(Hdrs)); err != nil {
+			return nil, err
+		}
+		dir = dir[n:]
+	}
+	return Hdrs, nil
+}
+
+// Prefix returns the number of bytes in front of the archive proper, the
+// stub of a self-extracting .exe or a shell script for example.  Zero for an
+// ordinary archive.  Some tools (zip -A) adjust the directory offsets to allow
+// for the prefix and some don't, either way the Header offsets from
+// CentralHeaders() are positions in the whole file.  Next() uses this to
+// skip the prefix when the archive doesn't start with a local header.
+func (r *ZipReader) Prefix() (int64, error) {
+	filelist, err := r.CentralHeaders()
+	if err != nil {
+		return 0, err
+	}
+	if r.split() {
+		return 0, nil
+	}
+	e, err := findEndCentDir(r.reader)
+	if err != nil {
+		return 0, err
+	}
+	prefix := e.offset - e.dirSize // empty archive, nothing before the directory
+	for _, hdr := range filelist {
+		if hdr.LocalOffset < prefix {
+			prefix = hdr.LocalOffset
+		}
+	}
+	return prefix, nil
+}
+
+// Comment returns the archive comment from the End of Central Directory record
+func (r *ZipReader) Comment() (string, error) {
+	e, err := findEndCentDir(r.reader)
+	if err != nil {
+		return "", err
+	}
+	return e.comment, nil
+}
+
+// unpackCentralHeader decodes one central directory record from the front of src
+// and returns the header plus the number of bytes the record used
+func (r *ZipReader) unpackCentralHeader(src []byte) (*Header, int, error) {
+	if len(src) < CentDirHdrSize {
+		return nil, 0, ShortReadError
+	}
+	if string(src[0:4]) != ZIP_CentDirSig {
+		return nil, 0, CentDirSigError
+	}
+	nameLen := int(sixteenBit(src[28:30]))
+	extraLen := int(sixteenBit(src[30:32]))
+	commentLen := int(sixteenBit(src[32:34]))
+	recLen := CentDirHdrSize + nameLen + extraLen + commentLen
+	if len(src) < recLen {
+		return nil, 0, ShortReadError
+	}
+	hdr := new(Header)
+	hdr.Hreader = r.reader
+	hdr.zr = r
+	hdr.VersionMadeBy = sixteenBit(src[4:6])
+	hdr.Flags = sixteenBit(src[8:10])
+	hdr.Compress = sixteenBit(src[10:12])
+	hdr.StoredCrc32 = thirtyTwoBit(src[16:20])
+	hdr.SizeCompr = int64(thirtyTwoBit(src[20:24]))
+	hdr.Size = int64(thirtyTwoBit(src[24:28]))
+	hdr.InternalAttrs = sixteenBit(src[36:38])
+	hdr.ExternalAttrs = thirtyTwoBit(src[38:42])
+	hdr.Name = string(src[CentDirHdrSize : CentDirHdrSize+nameLen])
+	if extraLen > 0 {
+		hdr.Extra = append([]byte(nil), src[CentDirHdrSize+nameLen:CentDirHdrSize+nameLen+extraLen]...)
+	}
+	hdr.Comment = string(src[CentDirHdrSize+nameLen+extraLen : recLen])
+	hdr.dosDate, hdr.dosTime = sixteenBit(src[14:16]), sixteenBit(src[12:14])
+	hdr.Mtime = makeGoDate(hdr.dosDate, hdr.dosTime)
+	hdr.Typeflag = hdr.typeflag()
+	localOffset, err := r.logical(sixteenBit(src[34:36]), int64(thirtyTwoBit(src[42:46])))
+	if err != nil {
+		return nil, 0, err
+	}
+	hdr.LocalOffset = localOffset
+	// Offset is left for DataOffset() to find, so a listing never has to
+	// visit the local headers
+	return hdr, recLen, nil
+}
+
+// DataOffset returns where the entry's (compressed) data begins.  Headers
+// from CentralHeaders() don't know this until the local header has been read,
+// because its name and extra lengths can differ from the central copy.
+// The answer is saved in Offset.
+func (h *Header) DataOffset() (int64, error) {
+	if h.Offset != 0 {
+		return h.Offset, nil
+	}
+	if h.Hreader == nil {
+		return 0, StreamOpenError
+	}
+	localHdr := make([]byte, LocalHdrSize)
+	if _, err := h.Hreader.Seek(h.LocalOffset, 0); err != nil {
+		return 0, err
+	}
+	if _, err := io.ReadFull(h.Hreader, localHdr); err != nil {
+		return 0, err
+	}
+	if string(localHdr[0:4]) != ZIP_LocalHdrSig {
+		return 0, InvalidSigError
+	}
+	h.Offset = h.LocalOffset + LocalHdrSize +
+		int64(sixteenBit(localHdr[26:28])) + int64(sixteenBit(localHdr[28:30]))
+	return h.Offset, nil
+}
+
+// Mode returns the entry's permission and type bits.  Unix creators (Info-ZIP
+// on unix and OS X) keep st_mode in the upper half of the external attributes.
+// Everything else falls back to the MSDOS attribute byte and a trailing slash
+// on the name.
+func (h *Header) Mode() os.FileMode {
+	var mode os.FileMode
+	switch h.VersionMadeBy >> 8 {
+	case creatorUnix, creatorMacOSX:
+		mode = unixModeToFileMode(h.ExternalAttrs >> 16)
+	case creatorFAT, creatorNTFS, creatorVFAT:
+		mode = msdosModeToFileMode(h.ExternalAttrs)
+	}
+	if mode == 0 { // a creator we don't know about
+		mode = 0644
+		if h.ExternalAttrs&msdosDir != 0 {
+			mode = os.ModeDir | 0755
+		}
+	}
+	if strings.HasSuffix(h.Name, "/") {
+		mode |= os.ModeDir
+	}
+	return mode
+}
+
+func unixModeToFileMode(m uint32) os.FileMode {
+	mode := os.FileMode(m & 0777)
+	switch m & s_IFMT {
+	case s_IFREG:
+		// nothing to add
+	case s_IFDIR:
+		mode |= os.ModeDir
+	case s_IFLNK:
+		mode |= os.ModeSymlink
+	case s_IFBLK:
+		mode |= os.ModeDevice
+	case s_IFCHR:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case s_IFIFO:
+		mode |= os.ModeNamedPipe
+	case s_IFSOCK:
+		mode |= os.ModeSocket
+	}
+	if m&s_ISUID != 0 {
+		mode |= os.ModeSetuid
+	}
+	if m&s_ISGID != 0 {
+		mode |= os.ModeSetgid
+	}
+	if m&s_ISVTX != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+func msdosModeToFileMode(m uint32) os.FileMode {
+	var mode os.FileMode
+	if m&msdosDir != 0 {
+		mode = os.ModeDir | 0777
+	} else {
+		mode = 0666
+	}
+	if m&msdosReadOnly != 0 {
+		mode &^= 0222
+	}
+	return mode
+}
+
+// typeflag converts Mode() into one of the tar style Type constants
+func (h *Header) typeflag() byte {
+	mode := h.Mode()
+	switch {
+	case mode&os.ModeDir != 0:
+		return TypeDir
+	case mode&os.ModeSymlink != 0:
+		return TypeSymlink
+	case mode&os.ModeCharDevice != 0:
+		return TypeChar
+	case mode&os.ModeDevice != 0:
+		return TypeBlock
+	case mode&os.ModeNamedPipe != 0:
+		return TypeFifo
+	case mode&os.ModeSocket != 0:
+		return TypeSocket
+	}
+	return TypeReg
+}
+
+// extra field holding unix modification, access and creation times
+const extTimestampID = 0x5455
+
+// extra field holding zip64 sizes and offsets, only true of the archive it is in
+const extZip64ID = 0x0001
+
+// ModTime is the modification time the way unzip shows it.  Info-ZIP adds an
+// extended timestamp extra field holding the unix time, that is used if
+// present (converted to local time), otherwise it's Mtime, the MSDOS date and
+// time read as if it were UTC.
+func (h *Header) ModTime() time.Time {
+	for extra := h.Extra; len(extra) >= 4; {
+		id, size := sixteenBit(extra[0:2]), int(sixteenBit(extra[2:4]))
+		if 4+size > len(extra) {
+			break
+		}
+		// flags byte, then the times it says are present, mtime first
+		if id == extTimestampID && size >= 5 && extra[4]&1 != 0 {
+			return time.Unix(int64(int32(thirtyTwoBit(extra[5:9]))), 0)
+		}
+		extra = extra[4+size:]
+	}
+	return h.Mtime
+}
+
+// Linkname returns the target of a symbolic link entry.  Info-ZIP stores the
+// target as the entry's contents so this is just Open() and read it all.
+func (h *Header) Linkname() (string, error) {
+	if h.Typeflag != TypeSymlink {
+		return "", NotSymlinkError
+	}
+	rdr, err := h.Open()
+	if err != nil {
+		return "", err
+	}
+	var target strings.Builder
+	if _, err = io.Copy(&target, rdr); err != nil {
+		return "", err
+	}
+	return target.String(), nil
+}
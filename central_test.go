@@ -0,0 +1,177 @@
+// central_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// Purpose: CentralHeaders() should find the same entries as Headers()
+// and point Offset at the same data
+func TestCentralHeaders(t *testing.T) {
+	fmt.Printf("TestCentralHeaders start\n")
+	const testfile = "testdata/phpBB.zip"
+	f, err := os.Open(testfile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	local, err := rz.Headers()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	central, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(local) != len(central) {
+		t.Fatalf("local headers (%d) != central headers (%d)", len(local), len(central))
+	}
+	for ndx, hdr := range central {
+		off, err := hdr.DataOffset()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if hdr.Name != local[ndx].Name || off != local[ndx].Offset || hdr.LocalOffset != local[ndx].LocalOffset ||
+			hdr.StoredCrc32 != local[ndx].StoredCrc32 || hdr.Size != local[ndx].Size {
+			t.Fatalf("central header %d (%s) doesn't match local header", ndx, hdr.Name)
+		}
+	}
+	if central[0].Typeflag != TypeDir || central[0].Mode() != os.ModeDir|0755 {
+		t.Errorf("%s: got mode %v, typeflag %c", central[0].Name, central[0].Mode(), central[0].Typeflag)
+	}
+	fmt.Printf("TestCentralHeaders fini\n")
+}
+
+// Purpose: unix permissions, directories and symlinks from an Info-ZIP archive
+// made with zip -y so the link is stored as a link
+func TestUnixModes(t *testing.T) {
+	fmt.Printf("TestUnixModes start\n")
+	const testfile = "testdata/unix.zip"
+	f, err := os.Open(testfile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := map[string]struct {
+		mode     os.FileMode
+		typeflag byte
+	}{
+		"unix/":             {os.ModeDir | 0755, TypeDir},
+		"unix/readme.txt":   {0644, TypeReg},
+		"unix/link.txt":     {os.ModeSymlink | 0777, TypeSymlink},
+		"unix/bin/":         {os.ModeDir | 0755, TypeDir},
+		"unix/bin/hello.sh": {0755, TypeReg},
+	}
+	if len(filelist) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(filelist))
+	}
+	for _, hdr := range filelist {
+		w, ok := want[hdr.Name]
+		if !ok {
+			t.Fatalf("unexpected entry %s", hdr.Name)
+		}
+		if hdr.Mode() != w.mode || hdr.Typeflag != w.typeflag {
+			t.Errorf("%s: got mode %v typeflag %c, want %v %c", hdr.Name,
+				hdr.Mode(), hdr.Typeflag, w.mode, w.typeflag)
+		}
+		if hdr.Typeflag == TypeSymlink {
+			target, err := hdr.Linkname()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if target != "readme.txt" {
+				t.Errorf("%s: link target %q, want %q", hdr.Name, target, "readme.txt")
+			}
+		} else if _, err := hdr.Linkname(); err != NotSymlinkError {
+			t.Errorf("%s: Linkname() on non-link returned %v", hdr.Name, err)
+		}
+	}
+	fmt.Printf("TestUnixModes fini\n")
+}
+
+// Purpose: a shell script stub in front of stuf.zip, once with the
+// offsets left alone and once fixed up by zip -A
+func TestPrefix(t *testing.T) {
+	fmt.Printf("TestPrefix start\n")
+	const stubLen = 79
+	want := contentsOf(t, "testdata/stuf.zip", "Makefile")
+	for _, testfile := range []string{"testdata/sfx.zip", "testdata/sfx-adjusted.zip"} {
+		f, err := os.Open(testfile)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer f.Close()
+		rz, err := NewReader(f)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		prefix, err := rz.Prefix()
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", testfile, err)
+		}
+		if prefix != stubLen {
+			t.Errorf("%s: prefix %d, want %d", testfile, prefix, stubLen)
+		}
+		central, err := rz.CentralHeaders()
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", testfile, err)
+		}
+		local, err := rz.Headers() // has to skip the stub to find the first header
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", testfile, err)
+		}
+		if len(local) != 1 || len(central) != 1 {
+			t.Fatalf("%s: expected 1 entry, got %d local %d central", testfile, len(local), len(central))
+		}
+		if central[0].LocalOffset != stubLen || local[0].LocalOffset != stubLen {
+			t.Errorf("%s: local header at %d (central) %d (local), want %d", testfile,
+				central[0].LocalOffset, local[0].LocalOffset, stubLen)
+		}
+		for _, hdr := range []*Header{central[0], local[0]} {
+			rdr, err := hdr.Open()
+			if err != nil {
+				t.Fatalf("%s: Unexpected error: %v", testfile, err)
+			}
+			got, _ := ioutil.ReadAll(rdr)
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: %s contents differ", testfile, hdr.Name)
+			}
+		}
+	}
+	// and an ordinary archive has no prefix
+	f, err := os.Open("testdata/phpBB.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if prefix, err := rz.Prefix(); prefix != 0 || err != nil {
+		t.Errorf("phpBB.zip: prefix %d, %v", prefix, err)
+	}
+	fmt.Printf("TestPrefix fini\n")
+}
@@ -0,0 +1,386 @@
+// unzip.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// unzip is a work-alike of Info-ZIP's unzip built on the library, so the
+// command line exercises the same Extract(), Open() and password code as
+// programs that use the package.  The common options are supported:
+//
+//	unzip [-l | -t | -p] [-o | -n | -u] [-j] [-q] [-P password] archive.zip
+//	      [pattern ...] [-x pattern ...] [-d dir]
+//
+// Patterns are unzip wildcards: * and ? match any characters including /,
+// [...] matches one of a set.  Without -o, -n or -u you are asked before
+// each existing file is replaced.  Without -P you are asked for a password
+// when an encrypted entry turns up (the password is echoed).
+//
+// Exit status follows unzip: 0 success, 1 some entries had problems, 2 the
+// archive is unreadable, 9 no such archive, 10 bad arguments, 11 nothing
+// matched the patterns, 82 only password failures.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/hotei/go-zipfile"
+)
+
+const (
+	exitOK        = 0
+	exitWarning   = 1
+	exitBadZip    = 2
+	exitNoZip     = 9
+	exitUsage     = 10
+	exitNoMatch   = 11
+	exitPassword  = 82
+	usageSynopsis = "usage: unzip [-l | -t | -p] [-o | -n | -u] [-j] [-q] [-P password] archive.zip [pattern ...] [-x pattern ...] [-d dir]\n"
+)
+
+// unzip is one run of the command
+type unzip struct {
+	list, test, pipe         bool
+	overwrite, never, update bool
+	junk, quiet              bool
+	password                 string
+	dir                      string
+	sel                      zipfile.Selector
+
+	stdin          *bufio.Reader
+	stdout, stderr io.Writer
+	replaceAll     int // 0 ask, 1 yes to all, -1 no to all
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run parses args and does the work, returning the exit status
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	u := &unzip{stdin: bufio.NewReader(stdin), stdout: stdout, stderr: stderr, dir: "."}
+	fs := flag.NewFlagSet("unzip", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usageSynopsis)
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&u.list, "l", false, "list the archive")
+	fs.BoolVar(&u.test, "t", false, "test the archive, decompress everything and check CRCs")
+	fs.BoolVar(&u.pipe, "p", false, "extract to stdout, no messages")
+	fs.BoolVar(&u.overwrite, "o", false, "overwrite existing files without asking")
+	fs.BoolVar(&u.never, "n", false, "never overwrite existing files")
+	fs.BoolVar(&u.update, "u", false, "replace existing files only if the entry is newer")
+	fs.BoolVar(&u.junk, "j", false, "junk paths, extract everything into one directory")
+	fs.BoolVar(&u.quiet, "q", false, "quiet")
+	fs.StringVar(&u.password, "P", "", "password for encrypted entries")
+	fs.StringVar(&u.dir, "d", ".", "extract into dir")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	rest := fs.Args()
+	if len(rest) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	archive := rest[0]
+	if err := u.patterns(rest[1:]); err != nil {
+		fmt.Fprintf(stderr, "unzip: %v\n", err)
+		return exitUsage
+	}
+	if u.pipe {
+		u.quiet = true
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		fmt.Fprintf(stderr, "unzip: cannot find or open %s\n", archive)
+		return exitNoZip
+	}
+	defer f.Close()
+	zipfile.Paranoid = false
+	rz, err := zipfile.NewReader(f)
+	if err != nil {
+		fmt.Fprintf(stderr, "unzip: %s: %v\n", archive, err)
+		return exitBadZip
+	}
+	selected, err := rz.Select(&u.sel)
+	if err != nil {
+		fmt.Fprintf(stderr, "unzip: %s: %v\n", archive, err)
+		return exitBadZip
+	}
+	if !u.quiet {
+		fmt.Fprintf(stdout, "Archive:  %s\n", archive)
+	}
+	if len(selected) == 0 {
+		fmt.Fprintf(stderr, "caution: filename not matched\n")
+		return exitNoMatch
+	}
+	if u.list {
+		u.listEntries(selected)
+		return exitOK
+	}
+	u.askPassword(rz, archive, selected)
+	switch {
+	case u.test:
+		return u.testEntries(archive, selected)
+	case u.pipe:
+		return u.pipeEntries(selected)
+	}
+	return u.extract(rz)
+}
+
+// patterns sorts what follows the archive name into include patterns,
+// exclude patterns (after -x) and the -d directory
+func (u *unzip) patterns(args []string) error {
+	excluding := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-x":
+			excluding = true
+			continue
+		case "-d":
+			if i+1 == len(args) {
+				return fmt.Errorf("-d needs a directory")
+			}
+			i++
+			u.dir = args[i]
+			continue
+		}
+		re, err := wildcard(args[i])
+		if err != nil {
+			return err
+		}
+		if excluding {
+			u.sel.Exclude = append(u.sel.Exclude, re)
+		} else {
+			u.sel.Include = append(u.sel.Include, re)
+		}
+	}
+	return nil
+}
+
+// wildcard turns an unzip pattern into a regexp
+func wildcard(pattern string) (*regexp.Regexp, error) {
+	var re bytes.Buffer
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in %q", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
+
+// askPassword prompts for a password if an entry needs one and -P wasn't given
+func (u *unzip) askPassword(rz *zipfile.ZipReader, archive string, hdrs []*zipfile.Header) {
+	if u.password != "" {
+		rz.SetPassword(u.password)
+		return
+	}
+	if u.list {
+		return
+	}
+	for _, h := range hdrs {
+		if !h.Encrypted() {
+			continue
+		}
+		fmt.Fprintf(u.stderr, "[%s] %s password: ", archive, h.Name)
+		line, err := u.stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return // no password to be had, entries will fail with PasswordError
+		}
+		rz.SetPassword(strings.TrimRight(line, "\r\n"))
+		return
+	}
+}
+
+// listEntries prints what "unzip -l" would
+func (u *unzip) listEntries(hdrs []*zipfile.Header) {
+	fmt.Fprintf(u.stdout, "  Length      Date    Time    Name\n")
+	fmt.Fprintf(u.stdout, "---------  ---------- -----   ----\n")
+	var total int64
+	for _, h := range hdrs {
+		fmt.Fprintf(u.stdout, "%9d  %s   %s\n", h.Size, h.ModTime().Format("2006-01-02 15:04"), h.Name)
+		total += h.Size
+	}
+	fmt.Fprintf(u.stdout, "---------                     -------\n")
+	files := "files"
+	if len(hdrs) == 1 {
+		files = "file"
+	}
+	fmt.Fprintf(u.stdout, "%9d                     %d %s\n", total, len(hdrs), files)
+}
+
+// testEntries decompresses every entry, Open() checks the CRC
+func (u *unzip) testEntries(archive string, hdrs []*zipfile.Header) int {
+	status := exitOK
+	failed := 0
+	for _, h := range hdrs {
+		err := readEntry(h, ioutil.Discard)
+		if !u.quiet || err != nil {
+			msg := "OK"
+			if err != nil {
+				msg = err.Error()
+			}
+			fmt.Fprintf(u.stdout, "    testing: %-24s %s\n", h.Name, msg)
+		}
+		if err != nil {
+			failed++
+			status = worse(status, entryStatus(err))
+		}
+	}
+	if failed == 0 {
+		fmt.Fprintf(u.stdout, "No errors detected in compressed data of %s.\n", archive)
+	} else {
+		fmt.Fprintf(u.stdout, "%d file(s) failed testing in %s.\n", failed, archive)
+	}
+	return status
+}
+
+// pipeEntries writes the contents of every entry to stdout
+func (u *unzip) pipeEntries(hdrs []*zipfile.Header) int {
+	status := exitOK
+	for _, h := range hdrs {
+		if h.Typeflag == zipfile.TypeDir {
+			continue
+		}
+		if err := readEntry(h, u.stdout); err != nil {
+			fmt.Fprintf(u.stderr, "unzip: %s: %v\n", h.Name, err)
+			status = worse(status, entryStatus(err))
+		}
+	}
+	return status
+}
+
+func readEntry(h *zipfile.Header, w io.Writer) error {
+	rdr, err := h.Open()
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, rdr)
+	return err
+}
+
+// extract writes the selected entries below u.dir
+func (u *unzip) extract(rz *zipfile.ZipReader) int {
+	opts := &zipfile.ExtractOptions{
+		Filter:    u.sel.Match, // Extract reads the directory itself
+		Overwrite: u.overwrite,
+		JunkPaths: u.junk,
+	}
+	switch {
+	case u.update:
+		opts.Replace = newer
+	case !u.overwrite && !u.never:
+		opts.Replace = u.ask
+	}
+	results, err := rz.Extract(u.dir, opts)
+	if err != nil {
+		fmt.Fprintf(u.stderr, "unzip: %v\n", err)
+		return exitBadZip
+	}
+	status := exitOK
+	for _, res := range results {
+		h := res.Header
+		switch {
+		case res.Err == zipfile.FileExistsError:
+			// left alone as asked
+		case res.Err != nil:
+			fmt.Fprintf(u.stderr, "   skipping: %-22s %v\n", h.Name, res.Err)
+			status = worse(status, entryStatus(res.Err))
+		case u.quiet:
+		case h.Typeflag == zipfile.TypeDir:
+			fmt.Fprintf(u.stdout, "   creating: %s\n", res.Path)
+		case h.Typeflag == zipfile.TypeSymlink:
+			fmt.Fprintf(u.stdout, "    linking: %s\n", res.Path)
+		case h.Compress == zipfile.ZIP_STORED:
+			fmt.Fprintf(u.stdout, " extracting: %s\n", res.Path)
+		default:
+			fmt.Fprintf(u.stdout, "  inflating: %s\n", res.Path)
+		}
+	}
+	return status
+}
+
+// newer is the -u policy, replace files older than the entry
+func newer(h *zipfile.Header, path string) bool {
+	fi, err := os.Lstat(path)
+	return err == nil && h.ModTime().After(fi.ModTime())
+}
+
+// ask is the default policy, the unzip prompt
+func (u *unzip) ask(h *zipfile.Header, path string) bool {
+	for u.replaceAll == 0 {
+		fmt.Fprintf(u.stderr, "replace %s? [y]es, [n]o, [A]ll, [N]one: ", path)
+		line, err := u.stdin.ReadString('\n')
+		if err != nil && line == "" {
+			u.replaceAll = -1 // nobody to ask
+			break
+		}
+		switch strings.TrimSpace(line) {
+		case "y", "Y":
+			return true
+		case "n":
+			return false
+		case "A":
+			u.replaceAll = 1
+		case "N":
+			u.replaceAll = -1
+		}
+	}
+	return u.replaceAll > 0
+}
+
+// entryStatus is the exit status an entry's error deserves
+func entryStatus(err error) int {
+	if err == zipfile.PasswordError || err == zipfile.BadPasswordError {
+		return exitPassword
+	}
+	return exitWarning
+}
+
+// worse combines statuses, a password failure only stands if nothing else went wrong
+func worse(a, b int) int {
+	switch {
+	case a == exitOK:
+		return b
+	case b == exitOK:
+		return a
+	case a == exitPassword && b == exitPassword:
+		return exitPassword
+	}
+	return exitWarning
+}
@@ -0,0 +1,126 @@
+// unzip_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Purpose: unzip wildcards match the way Info-ZIP's do
+func TestWildcard(t *testing.T) {
+	fmt.Printf("TestWildcard start\n")
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.txt", "unix/readme.txt", true}, // * crosses directories
+		{"*.txt", "unix/bin/hello.sh", false},
+		{"unix/?in/*", "unix/bin/hello.sh", true},
+		{"[a-s]*.txt", "secret.txt", true},
+		{"[!a-s]*.txt", "secret.txt", false},
+		{"a.b", "axb", false},
+		{`\*`, "*", true},
+	}
+	for _, tt := range tests {
+		re, err := wildcard(tt.pattern)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := re.MatchString(tt.name); got != tt.want {
+			t.Errorf("%q on %q: got %v, expected %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+	if _, err := wildcard("[abc"); err == nil {
+		t.Errorf("unterminated class accepted")
+	}
+	fmt.Printf("TestWildcard fini\n")
+}
+
+// Purpose: include and exclude patterns, junked paths and -n on a second run
+func TestExtract(t *testing.T) {
+	fmt.Printf("TestExtract start\n")
+	dir, err := ioutil.TempDir("", "unzip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	args := []string{"-j", "../../testdata/unix.zip", "unix/*", "-x", "*.txt", "-d", dir}
+	if status := run(args, strings.NewReader(""), &stdout, &stderr); status != exitOK {
+		t.Fatalf("exit %d: %s", status, stderr.String())
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(names) != 1 || filepath.Base(names[0]) != "hello.sh" {
+		t.Fatalf("extracted %v", names)
+	}
+	if !strings.Contains(stdout.String(), "hello.sh") {
+		t.Errorf("no progress line in %q", stdout.String())
+	}
+
+	ioutil.WriteFile(names[0], []byte("mine"), 0644)
+	args = []string{"-n", "-q", "-j", "-d", dir, "../../testdata/unix.zip", "unix/bin/hello.sh"}
+	if status := run(args, strings.NewReader(""), &stdout, &stderr); status != exitOK {
+		t.Fatalf("exit %d: %s", status, stderr.String())
+	}
+	if b, _ := ioutil.ReadFile(names[0]); string(b) != "mine" {
+		t.Errorf("-n replaced the file")
+	}
+	// answering the prompt with "y"
+	args = []string{"-q", "-j", "-d", dir, "../../testdata/unix.zip", "unix/bin/hello.sh"}
+	if status := run(args, strings.NewReader("y\n"), &stdout, &stderr); status != exitOK {
+		t.Fatalf("exit %d: %s", status, stderr.String())
+	}
+	if b, _ := ioutil.ReadFile(names[0]); string(b) == "mine" {
+		t.Errorf("prompt answer ignored")
+	}
+
+	args = []string{"-d", dir, "../../testdata/unix.zip", "nothing*"}
+	if status := run(args, strings.NewReader(""), &stdout, &stderr); status != exitNoMatch {
+		t.Errorf("unmatched pattern: exit %d", status)
+	}
+	fmt.Printf("TestExtract fini\n")
+}
+
+// Purpose: -t and -p on an encrypted archive, with the password from -P
+// or typed in, and a wrong one
+func TestPassword(t *testing.T) {
+	fmt.Printf("TestPassword start\n")
+	var stdout, stderr bytes.Buffer
+	args := []string{"-t", "-P", "swordfish", "../../testdata/crypt.zip"}
+	if status := run(args, strings.NewReader(""), &stdout, &stderr); status != exitOK {
+		t.Fatalf("exit %d: %s%s", status, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "No errors detected") {
+		t.Errorf("unexpected -t output %q", stdout.String())
+	}
+
+	stdout.Reset()
+	args = []string{"-p", "../../testdata/crypt.zip", "secret.txt"}
+	if status := run(args, strings.NewReader("swordfish\n"), &stdout, &stderr); status != exitOK {
+		t.Fatalf("exit %d: %s", status, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "secret") {
+		t.Errorf("unexpected -p output %q", stdout.String())
+	}
+
+	stdout.Reset()
+	args = []string{"-t", "-P", "tuna", "../../testdata/crypt.zip", "s*"}
+	if status := run(args, strings.NewReader(""), &stdout, &stderr); status != exitPassword {
+		t.Errorf("wrong password: exit %d: %s", status, stdout.String())
+	}
+	fmt.Printf("TestPassword fini\n")
+}
@@ -0,0 +1,399 @@
+// zip.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// zip creates archives with the library's Writer, so images and containers
+// that have Go binaries don't need Info-ZIP as well.
+//
+//	zip [-r] [-g] [-0 | -Z method] [-level n] [-y] [-D] [-q] [-deterministic]
+//	    archive.zip file ... [-x pattern ...]
+//
+// Permissions and modification times are recorded as they are on disk.
+// Symbolic links are followed unless -y is given, in which case the link
+// itself is stored; a link to a directory the recursion is already inside is
+// skipped with a warning.  Exclude patterns are shell patterns (path.Match) tried
+// against both the whole name and its last element, so "*.o" or ".git"
+// work anywhere in the tree.  -deterministic uses the Writer's Deterministic
+// mode, the same tree gives the same bytes: entries sorted by name, every
+// time stamp $SOURCE_DATE_EPOCH (1980-01-01 if unset) and permissions 0644,
+// or 0755 for directories and executables.
+//
+// The archive is written from scratch, to a temporary file that replaces
+// archive.zip only when everything went well.  With -g (grow) the files are
+// appended to an existing archive in place instead, see OpenAppend, and
+// names it already has are skipped with a warning.
+//
+// Exit status follows Info-ZIP: 0 success, 3 the archive to grow is damaged,
+// 12 nothing to do, 15 the archive couldn't be written, 16 bad arguments, 18
+// some files couldn't be read or were already there (the archive is still
+// written without them).
+
+package main
+
+import (
+	"compress/flate"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hotei/go-zipfile"
+)
+
+const (
+	exitOK      = 0
+	exitFormat  = 3
+	exitNothing = 12
+	exitCreate  = 15
+	exitUsage   = 16
+	exitOpen    = 18
+)
+
+// zipper is one run of the command
+type zipper struct {
+	recurse, noDirs, symlinks  bool
+	quiet, deterministic, grow bool
+	method                     uint16
+	level                      int
+	exclude                    []string
+
+	stdout, stderr io.Writer
+	zw             *zipfile.Writer
+	self           os.FileInfo // the temporary output, never added to itself
+	target         os.FileInfo // an existing archive being replaced or grown, same
+	names          map[string]bool
+	dirs           []os.FileInfo   // directories being recursed into, to catch symlink loops
+	pending        *zipfile.Header // last entry created, sizes known after the next Create
+	added          int
+	status         int
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run parses args and does the work, returning the exit status
+func run(args []string, stdout, stderr io.Writer) int {
+	z := &zipper{stdout: stdout, stderr: stderr, names: make(map[string]bool)}
+	fs := flag.NewFlagSet("zip", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: zip [-r] [-g] [-0 | -Z method] [-level n] [-y] [-D] [-q] [-deterministic] archive.zip file ... [-x pattern ...]\n")
+		fs.PrintDefaults()
+	}
+	var store bool
+	var method string
+	fs.BoolVar(&z.recurse, "r", false, "recurse into directories")
+	fs.BoolVar(&z.grow, "g", false, "grow, append to an existing archive in place")
+	fs.BoolVar(&store, "0", false, "store only, same as -Z store")
+	fs.StringVar(&method, "Z", "deflate", "compression method, store, deflate or the number of a registered method")
+	fs.IntVar(&z.level, "level", flate.DefaultCompression, "deflate level, 1 (fastest) to 9 (smallest)")
+	fs.BoolVar(&z.symlinks, "y", false, "store symbolic links as links instead of what they point at")
+	fs.BoolVar(&z.noDirs, "D", false, "don't add entries for directories")
+	fs.BoolVar(&z.quiet, "q", false, "quiet")
+	fs.BoolVar(&z.deterministic, "deterministic", false, "same input, same archive: fixed times and permissions")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	rest := fs.Args()
+	var files []string
+	for ndx, arg := range rest {
+		if arg == "-x" {
+			z.exclude = append(z.exclude, rest[ndx+1:]...)
+			break
+		}
+		files = append(files, arg)
+	}
+	if len(files) < 2 {
+		fs.Usage()
+		return exitUsage
+	}
+	for _, pat := range z.exclude {
+		if _, err := path.Match(pat, ""); err != nil {
+			fmt.Fprintf(stderr, "zip: bad exclude pattern %q\n", pat)
+			return exitUsage
+		}
+	}
+	if store {
+		method = "store"
+	}
+	m, err := parseMethod(method)
+	if err != nil {
+		fmt.Fprintf(stderr, "zip: %v\n", err)
+		return exitUsage
+	}
+	z.method = m
+	if z.level != flate.DefaultCompression && (z.level < flate.BestSpeed || z.level > flate.BestCompression) {
+		fmt.Fprintf(stderr, "zip: -level must be 1 to 9\n")
+		return exitUsage
+	}
+	return z.create(files[0], files[1:])
+}
+
+// parseMethod turns a -Z argument into a method the Writer has a compressor for
+func parseMethod(s string) (uint16, error) {
+	var m uint16
+	switch strings.ToLower(s) {
+	case "store", "stored":
+		m = zipfile.ZIP_STORED
+	case "deflate", "deflated":
+		m = zipfile.ZIP_DEFLATED
+	default:
+		n, err := strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("unknown compression method %q", s)
+		}
+		m = uint16(n)
+	}
+	for _, have := range zipfile.Compressors() {
+		if have == m {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("no compressor registered for method %s", zipfile.MethodName(m))
+}
+
+// create writes the archive to a temporary file and moves it into place
+func (z *zipper) create(archive string, files []string) int {
+	if z.grow {
+		if _, err := os.Stat(archive); err == nil {
+			return z.appendTo(archive, files)
+		}
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(archive), "zi")
+	if err != nil {
+		fmt.Fprintf(z.stderr, "zip: %v\n", err)
+		return exitCreate
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	z.self, _ = tmp.Stat()
+	z.target, _ = os.Stat(archive)
+	z.setWriter(zipfile.NewWriter(tmp))
+	if err = z.addAll(files); err == nil {
+		err = z.zw.Close()
+		z.report()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintf(z.stderr, "zip: %v\n", err)
+		return exitCreate
+	}
+	if z.added == 0 {
+		fmt.Fprintf(z.stderr, "zip error: Nothing to do! (%s)\n", archive)
+		return exitNothing
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err == nil {
+		err = os.Rename(tmp.Name(), archive)
+	}
+	if err != nil {
+		fmt.Fprintf(z.stderr, "zip: %v\n", err)
+		return exitCreate
+	}
+	return z.status
+}
+
+// appendTo adds files to the existing archive in place
+func (z *zipper) appendTo(archive string, files []string) int {
+	zw, err := zipfile.OpenAppend(archive)
+	if err != nil {
+		fmt.Fprintf(z.stderr, "zip: %s: %v\n", archive, err)
+		return exitFormat
+	}
+	z.target, _ = os.Stat(archive)
+	z.setWriter(zw)
+	if err = z.addAll(files); err == nil {
+		err = z.zw.Close()
+		z.report()
+	}
+	if err != nil {
+		fmt.Fprintf(z.stderr, "zip: %v\n", err)
+		return exitCreate
+	}
+	if z.added == 0 {
+		fmt.Fprintf(z.stderr, "zip error: Nothing to do! (%s)\n", archive)
+		return exitNothing
+	}
+	return z.status
+}
+
+// setWriter makes zw the Writer entries go to, set up as the flags say
+func (z *zipper) setWriter(zw *zipfile.Writer) {
+	z.zw = zw
+	z.zw.Deterministic = z.deterministic
+	if level := z.level; level != flate.DefaultCompression {
+		z.zw.RegisterCompressor(zipfile.ZIP_DEFLATED, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, level)
+		})
+	}
+}
+
+// addAll adds the files named on the command line
+func (z *zipper) addAll(files []string) error {
+	for _, file := range files {
+		if err := z.add(file, entryName(file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryName turns a path from the command line into an archive name the way
+// zip does: forward slashes, no leading "/", "./" or "../"
+func entryName(file string) string {
+	name := path.Clean(filepath.ToSlash(file))
+	for {
+		switch {
+		case strings.HasPrefix(name, "/"):
+			name = name[1:]
+		case strings.HasPrefix(name, "../"):
+			name = name[3:]
+		case name == "." || name == "..":
+			return ""
+		default:
+			return name
+		}
+	}
+}
+
+// excluded is true if name or its last element matches an exclude pattern
+func (z *zipper) excluded(name string) bool {
+	for _, pat := range z.exclude {
+		if ok, _ := path.Match(pat, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pat, path.Base(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// warn notes a file that couldn't be added, the rest still go in
+func (z *zipper) warn(file string, err error) {
+	fmt.Fprintf(z.stderr, "zip warning: %s: %v\n", file, err)
+	z.status = exitOpen
+}
+
+// add puts file into the archive under name, and what's below it if it is a
+// directory and -r was given.  Only errors writing the archive are returned.
+func (z *zipper) add(file, name string) error {
+	if name != "" && z.excluded(name) {
+		return nil
+	}
+	fi, err := os.Lstat(file)
+	if err != nil {
+		z.warn(file, err)
+		return nil
+	}
+	if fi.Mode()&os.ModeSymlink != 0 && !z.symlinks {
+		if fi, err = os.Stat(file); err != nil {
+			z.warn(file, err)
+			return nil
+		}
+	}
+	if os.SameFile(fi, z.self) || (z.target != nil && os.SameFile(fi, z.target)) {
+		return nil
+	}
+	switch {
+	case fi.IsDir():
+		for _, dir := range z.dirs {
+			if os.SameFile(fi, dir) {
+				z.warn(file, fmt.Errorf("directory loop, not followed"))
+				return nil
+			}
+		}
+		if name != "" && !z.noDirs {
+			if err = z.addEntry(name+"/", fi, nil); err != nil {
+				return err
+			}
+		}
+		if !z.recurse {
+			return nil
+		}
+		list, err := ioutil.ReadDir(file)
+		if err != nil {
+			z.warn(file, err)
+			return nil
+		}
+		z.dirs = append(z.dirs, fi)
+		defer func() { z.dirs = z.dirs[:len(z.dirs)-1] }()
+		for _, sub := range list {
+			if err = z.add(filepath.Join(file, sub.Name()), path.Join(name, sub.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	case fi.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(file)
+		if err != nil {
+			z.warn(file, err)
+			return nil
+		}
+		return z.addEntry(name, fi, strings.NewReader(target))
+	case !fi.Mode().IsRegular():
+		z.warn(file, fmt.Errorf("not a regular file, skipped"))
+		return nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		z.warn(file, err)
+		return nil
+	}
+	defer f.Close()
+	return z.addEntry(name, fi, f)
+}
+
+// addEntry writes one entry, contents from data
+func (z *zipper) addEntry(name string, fi os.FileInfo, data io.Reader) error {
+	if z.names[name] {
+		return nil // named twice on the command line
+	}
+	z.names[name] = true
+	h := &zipfile.Header{Name: name, Mtime: fi.ModTime(), Compress: z.method}
+	h.SetMode(fi.Mode())
+	fw, err := z.zw.Create(h)
+	if err == zipfile.DuplicateNameError {
+		z.warn(name, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	z.report()
+	z.pending = h
+	z.added++
+	if data == nil {
+		return nil
+	}
+	_, err = io.Copy(fw, data)
+	return err
+}
+
+// report prints the "adding:" line for the entry just finished
+func (z *zipper) report() {
+	h := z.pending
+	z.pending = nil
+	if h == nil || z.quiet {
+		return
+	}
+	switch {
+	case h.Compress == zipfile.ZIP_STORED:
+		fmt.Fprintf(z.stdout, "  adding: %s (stored 0%%)\n", h.Name)
+	default:
+		saved := 0
+		if h.Size > 0 {
+			saved = int((h.Size - h.SizeCompr) * 100 / h.Size)
+		}
+		fmt.Fprintf(z.stdout, "  adding: %s (%s %d%%)\n", h.Name, strings.ToLower(zipfile.MethodName(h.Compress)), saved)
+	}
+}
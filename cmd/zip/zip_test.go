@@ -0,0 +1,202 @@
+// zip_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hotei/go-zipfile"
+)
+
+// makeTree builds a small directory tree to archive
+func makeTree(t *testing.T, dir string) {
+	files := []struct {
+		name string
+		mode os.FileMode
+		data string
+	}{
+		{"src/a.txt", 0644, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\n"},
+		{"src/run.sh", 0750, "#!/bin/sh\necho hi\n"},
+		{"src/sub/b.o", 0644, "object code"},
+		{"src/sub/c.txt", 0600, "c\n"},
+	}
+	for _, f := range files {
+		p := filepath.Join(dir, f.name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(f.data), f.mode); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		os.Chmod(p, f.mode) // umask
+	}
+	if err := os.Symlink("a.txt", filepath.Join(dir, "src/link")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	mtime := time.Date(2012, 3, 4, 5, 6, 8, 0, time.UTC)
+	os.Chtimes(filepath.Join(dir, "src/a.txt"), mtime, mtime)
+}
+
+func readArchive(t *testing.T, archive string) map[string]*zipfile.Header {
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := zipfile.NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	hdrs := make(map[string]*zipfile.Header)
+	for _, h := range filelist {
+		hdrs[h.Name] = h
+	}
+	return hdrs
+}
+
+// Purpose: -r with excludes, symlinks kept with -y, modes and times carried over
+func TestZip(t *testing.T) {
+	fmt.Printf("TestZip start\n")
+	dir, err := ioutil.TempDir("", "zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	makeTree(t, dir)
+	archive := filepath.Join(dir, "out.zip")
+	var stdout, stderr bytes.Buffer
+	args := []string{"-r", "-y", archive, filepath.Join(dir, "src"), "-x", "*.o"}
+	if status := run(args, &stdout, &stderr); status != exitOK {
+		t.Fatalf("exit %d: %s", status, stderr.String())
+	}
+	hdrs := readArchive(t, archive)
+	prefix := entryName(filepath.Join(dir, "src")) + "/"
+	want := []string{"", "a.txt", "run.sh", "link", "sub/", "sub/c.txt"}
+	if len(hdrs) != len(want) {
+		t.Errorf("got %d entries, expected %d: %s", len(hdrs), len(want), stdout.String())
+	}
+	for _, name := range want {
+		if hdrs[prefix+name] == nil {
+			t.Errorf("%s missing", prefix+name)
+		}
+	}
+	if h := hdrs[prefix+"run.sh"]; h != nil && h.Mode().Perm() != 0750 {
+		t.Errorf("run.sh has mode %v", h.Mode())
+	}
+	if h := hdrs[prefix+"link"]; h != nil && h.Typeflag != zipfile.TypeSymlink {
+		t.Errorf("link stored as %c", h.Typeflag)
+	}
+	if h := hdrs[prefix+"a.txt"]; h != nil && !h.Mtime.Equal(time.Date(2012, 3, 4, 5, 6, 8, 0, time.UTC)) {
+		t.Errorf("a.txt has mtime %v", h.Mtime)
+	}
+	if !bytes.Contains(stdout.Bytes(), []byte("a.txt (deflated")) {
+		t.Errorf("no adding line in %q", stdout.String())
+	}
+
+	// without -y the link is followed, -0 stores
+	args = []string{"-q", "-0", "-D", archive, filepath.Join(dir, "src/link")}
+	if status := run(args, &stdout, &stderr); status != exitOK {
+		t.Fatalf("exit %d: %s", status, stderr.String())
+	}
+	hdrs = readArchive(t, archive)
+	h := hdrs[prefix+"link"]
+	if len(hdrs) != 1 || h == nil || h.Typeflag != zipfile.TypeReg || h.Compress != zipfile.ZIP_STORED || h.Size != 49 {
+		t.Errorf("followed link: %+v", hdrs)
+	}
+
+	// -g adds to it, a name already there is a warning
+	args = []string{"-q", "-g", archive, filepath.Join(dir, "src/link"), filepath.Join(dir, "src/sub/c.txt")}
+	if status := run(args, &stdout, &stderr); status != exitOpen {
+		t.Fatalf("exit %d: %s", status, stderr.String())
+	}
+	hdrs = readArchive(t, archive)
+	if len(hdrs) != 2 || hdrs[prefix+"sub/c.txt"] == nil {
+		t.Errorf("grown archive: %+v", hdrs)
+	}
+
+	// without -y a link back up the tree is a loop, skipped with a warning
+	loop := filepath.Join(dir, "loop")
+	if err := os.MkdirAll(filepath.Join(loop, "sub"), 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := os.Symlink("..", filepath.Join(loop, "sub", "up")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stderr.Reset()
+	args = []string{"-q", "-r", archive, loop}
+	if status := run(args, &stdout, &stderr); status != exitOpen {
+		t.Fatalf("exit %d: %s", status, stderr.String())
+	}
+	if hdrs = readArchive(t, archive); len(hdrs) != 2 || !bytes.Contains(stderr.Bytes(), []byte("directory loop")) {
+		t.Errorf("loop: %d entries, %q", len(hdrs), stderr.String())
+	}
+
+	if status := run([]string{"-Z", "bogus", archive, dir}, &stdout, &stderr); status != exitUsage {
+		t.Errorf("bad method: exit %d", status)
+	}
+	if status := run([]string{archive, filepath.Join(dir, "nope")}, &stdout, &stderr); status != exitNothing {
+		t.Errorf("nothing to do: exit %d", status)
+	}
+	fmt.Printf("TestZip fini\n")
+}
+
+// Purpose: -deterministic gives identical archives from trees that differ
+// only in time stamps and group/other permissions
+func TestZipDeterministic(t *testing.T) {
+	fmt.Printf("TestZipDeterministic start\n")
+	dir, err := ioutil.TempDir("", "zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.Chdir(wd)
+	os.Setenv("SOURCE_DATE_EPOCH", "1334000000")
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+	var sums [2][]byte
+	for ndx := range sums {
+		tree := filepath.Join(dir, fmt.Sprintf("t%d", ndx))
+		makeTree(t, tree)
+		if ndx == 1 {
+			os.Chmod(filepath.Join(tree, "src/sub/c.txt"), 0644)
+			now := time.Now()
+			os.Chtimes(filepath.Join(tree, "src/a.txt"), now, now)
+		}
+		archive := filepath.Join(dir, fmt.Sprintf("t%d.zip", ndx))
+		var stdout, stderr bytes.Buffer
+		os.Chdir(tree)
+		status := run([]string{"-r", "-deterministic", archive, "src"}, &stdout, &stderr)
+		if status != exitOK {
+			t.Fatalf("exit %d: %s", status, stderr.String())
+		}
+		if sums[ndx], err = ioutil.ReadFile(archive); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if !bytes.Equal(sums[0], sums[1]) {
+		t.Errorf("archives differ")
+	}
+	hdrs := readArchive(t, filepath.Join(dir, "t0.zip"))
+	if h := hdrs["src/run.sh"]; h == nil || h.Mode().Perm() != 0755 || h.Mtime.Unix() != 1334000000 {
+		t.Errorf("run.sh: %+v", h)
+	}
+	fmt.Printf("TestZipDeterministic fini\n")
+}
@@ -0,0 +1,281 @@
+// zipcheck.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// zipcheck checks that archives can still be read, which is the point of
+// the library for old diskettes and CDs.  Every entry of every archive is
+// decompressed and its CRC checked.  Directories are walked for files
+// ending in one of the -ext suffixes.  Archives are checked -j at a time but
+// reported in the order given.  Split archives are checked as a whole, name
+// the last piece (the .zip), the .z01 ... pieces are found from it.
+//
+//	zipcheck [-j n] [-q] [-ext .zip,.jar] path ...
+//
+// Entries that are encrypted or use a compression method the library can't
+// expand can't be checked, they are reported as "unsupported/encrypted".
+//
+// Exit status is 0 if everything is OK, 1 if the only problems are bad
+// dates, 2 if any archive is damaged or has entries that couldn't be
+// checked, and 3 if a path couldn't be read at all or the arguments were
+// wrong.
+
+package main
+
+import (
+	"compress/flate"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hotei/go-zipfile"
+)
+
+var (
+	flagJobs  = flag.Int("j", runtime.NumCPU(), "archives to check at once")
+	flagQuiet = flag.Bool("q", false, "only report archives with problems")
+	flagExt   = flag.String("ext", ".zip", "comma separated suffixes to look for in directories")
+)
+
+// status of an entry or archive, worse problems have bigger values
+type status int
+
+const (
+	statusOK status = iota
+	statusBadDate
+	statusUnsupported
+	statusCRC
+	statusBadSig
+	statusTruncated
+	statusError
+)
+
+var statusNames = []string{"OK", "bad date", "unsupported/encrypted", "CRC mismatch", "bad signature", "truncated", "error"}
+
+func (s status) String() string {
+	return statusNames[s]
+}
+
+// problem is one thing wrong, Entry is "" when it's the archive as a whole
+type problem struct {
+	Entry  string
+	Status status
+	Err    error
+}
+
+type report struct {
+	Path     string
+	Entries  int
+	Status   status
+	Problems []problem
+}
+
+func (r *report) add(entry string, err error) {
+	p := problem{Entry: entry, Status: classify(err), Err: err}
+	r.Problems = append(r.Problems, p)
+	if p.Status > r.Status {
+		r.Status = p.Status
+	}
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zipcheck [-j n] [-q] [-ext .zip,.jar] path ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *flagJobs < 1 {
+		flag.Usage()
+		os.Exit(3)
+	}
+	zipfile.Paranoid = false // report problems, don't exit on them
+	paths, walkErr := findArchives(flag.Args(), strings.Split(*flagExt, ","))
+	worst := statusOK
+	for r := range checkAll(paths, *flagJobs) {
+		if r.Status > worst {
+			worst = r.Status
+		}
+		if r.Status != statusOK || !*flagQuiet {
+			printReport(os.Stdout, r)
+		}
+	}
+	switch {
+	case walkErr || worst == statusError:
+		os.Exit(3)
+	case worst > statusBadDate:
+		os.Exit(2)
+	case worst == statusBadDate:
+		os.Exit(1)
+	}
+}
+
+// findArchives expands directories into the archives under them.  Paths
+// named on the command line are checked whatever they're called.
+func findArchives(args, exts []string) ([]string, bool) {
+	var paths []string
+	failed := false
+	for _, arg := range args {
+		err := filepath.Walk(arg, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "zipcheck: %v\n", err)
+				failed = true
+				return nil
+			}
+			if path == arg && !fi.IsDir() {
+				paths = append(paths, path)
+				return nil
+			}
+			if fi.Mode().IsRegular() && hasExt(path, exts) {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "zipcheck: %v\n", err)
+			failed = true
+		}
+	}
+	return paths, failed
+}
+
+func hasExt(path string, exts []string) bool {
+	for _, ext := range exts {
+		if ext != "" && strings.EqualFold(filepath.Ext(path), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAll checks up to jobs archives at once and sends back the reports in
+// the same order as paths
+func checkAll(paths []string, jobs int) <-chan *report {
+	slots := make([]chan *report, len(paths))
+	for ndx := range slots {
+		slots[ndx] = make(chan *report, 1)
+	}
+	limit := make(chan bool, jobs)
+	go func() {
+		for ndx, path := range paths {
+			limit <- true
+			go func(ndx int, path string) {
+				slots[ndx] <- checkArchive(path)
+				<-limit
+			}(ndx, path)
+		}
+	}()
+	out := make(chan *report)
+	go func() {
+		for _, slot := range slots {
+			out <- <-slot
+		}
+		close(out)
+	}()
+	return out
+}
+
+// checkArchive reads every entry in the archive at path.  The last piece of
+// a split archive brings in the others (path.z01 ...).
+func checkArchive(path string) *report {
+	r := &report{Path: path}
+	v, err := zipfile.OpenSplit(path)
+	if err != nil {
+		r.add("", err)
+		return r
+	}
+	defer v.Close()
+	rz, err := zipfile.NewVolumeReader(v)
+	if err != nil {
+		r.add("", err)
+		return r
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		// no usable directory, see how much the local headers give us
+		r.add("", err)
+		if filelist, err = localHeaders(v); err != nil {
+			r.add("", err)
+		}
+	}
+	r.Entries = len(filelist)
+	for _, hdr := range filelist {
+		if err := hdr.CheckDate(); err != nil {
+			r.add(hdr.Name, err)
+		}
+		if err := readEntry(hdr); err != nil {
+			r.add(hdr.Name, err)
+		}
+	}
+	return r
+}
+
+// localHeaders is ZipReader.Headers() but keeps what it found before an error
+func localHeaders(v *zipfile.Volumes) ([]*zipfile.Header, error) {
+	rz, err := zipfile.NewVolumeReader(v)
+	if err != nil {
+		return nil, err
+	}
+	var filelist []*zipfile.Header
+	for {
+		hdr, err := rz.Next()
+		if err != nil {
+			return filelist, err
+		}
+		if hdr == nil {
+			return filelist, nil
+		}
+		filelist = append(filelist, hdr)
+	}
+}
+
+// readEntry decompresses an entry, Open() checks the CRC
+func readEntry(hdr *zipfile.Header) error {
+	rdr, err := hdr.Open()
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(ioutil.Discard, rdr)
+	return err
+}
+
+// classify sorts errors from the library into the statuses we report
+func classify(err error) status {
+	switch err {
+	case zipfile.BadDateError, zipfile.FutureTimeError:
+		return statusBadDate
+	case zipfile.PasswordError, zipfile.InvalidCompError:
+		return statusUnsupported
+	case zipfile.CRC32MatchError:
+		return statusCRC
+	case zipfile.InvalidSigError, zipfile.CentDirSigError, zipfile.BadEndCentDirError:
+		return statusBadSig
+	case zipfile.ShortReadError, zipfile.NoEndCentDirError, io.EOF, io.ErrUnexpectedEOF:
+		return statusTruncated
+	}
+	if _, ok := err.(flate.CorruptInputError); ok {
+		return statusCRC // damaged data, the CRC would have failed too
+	}
+	return statusError
+}
+
+func printReport(w io.Writer, r *report) {
+	fmt.Fprintf(w, "%s: %v (%d entries", r.Path, r.Status, r.Entries)
+	if len(r.Problems) > 0 {
+		fmt.Fprintf(w, ", %d problems", len(r.Problems))
+	}
+	fmt.Fprintf(w, ")\n")
+	for _, p := range r.Problems {
+		name := p.Entry
+		if name == "" {
+			name = "archive"
+		}
+		fmt.Fprintf(w, "    %s: %v: %v\n", name, p.Status, p.Err)
+	}
+}
@@ -0,0 +1,107 @@
+// zipcheck_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hotei/go-zipfile"
+)
+
+// offsets in testdata/mini.zip
+const (
+	miniData    = 66 // mini.txt's 5 stored bytes
+	miniCentral = 71 // central directory record
+)
+
+// damaged copies of mini.zip and what zipcheck should make of them
+var damages = []struct {
+	name   string
+	damage func([]byte) []byte
+	want   status
+}{
+	{"good.zip", func(b []byte) []byte { return b }, statusOK},
+	{"crc.zip", func(b []byte) []byte { b[miniData] ^= 0xff; return b }, statusCRC},
+	{"date.zip", func(b []byte) []byte {
+		b[miniCentral+14], b[miniCentral+15] = 0xa1, 0x3d // month 13
+		return b
+	}, statusBadDate},
+	{"sig.zip", func(b []byte) []byte { b[0] = 'X'; return b }, statusBadSig},
+	{"short.zip", func(b []byte) []byte { return b[:miniData+2] }, statusTruncated},
+}
+
+// Purpose: each kind of damage is reported as such, in the order given
+func TestCheck(t *testing.T) {
+	fmt.Printf("TestCheck start\n")
+	mini, err := ioutil.ReadFile("../../testdata/mini.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "zipcheck")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, d := range damages {
+		b := d.damage(append([]byte(nil), mini...))
+		if err := ioutil.WriteFile(filepath.Join(dir, d.name), b, 0644); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an archive"), 0644)
+
+	var paths []string
+	for _, d := range damages {
+		paths = append(paths, filepath.Join(dir, d.name))
+	}
+	ndx := 0
+	for r := range checkAll(paths, 3) {
+		d := damages[ndx]
+		if r.Path != paths[ndx] {
+			t.Fatalf("report %d is for %s, expected %s", ndx, r.Path, paths[ndx])
+		}
+		if r.Status != d.want {
+			t.Errorf("%s: got %v, expected %v: %+v", d.name, r.Status, d.want, r.Problems)
+		}
+		ndx++
+	}
+	if ndx != len(damages) {
+		t.Errorf("got %d reports, expected %d", ndx, len(damages))
+	}
+
+	found, failed := findArchives([]string{dir}, []string{".zip"})
+	if failed || len(found) != len(damages) {
+		t.Errorf("walk found %v", found)
+	}
+	fmt.Printf("TestCheck fini\n")
+}
+
+// Purpose: split and self-extracting archives aren't mistaken for damage
+func TestCheckOddArchives(t *testing.T) {
+	fmt.Printf("TestCheckOddArchives start\n")
+	for _, name := range []string{"split.zip", "sfx.zip", "sfx-adjusted.zip"} {
+		r := checkArchive("../../testdata/" + name)
+		if r.Status != statusOK || r.Entries == 0 {
+			t.Errorf("%s: %v with %d entries: %+v", name, r.Status, r.Entries, r.Problems)
+		}
+	}
+	// encrypted entries can't be checked without a password, that's not
+	// damage and not an operator error either
+	r := checkArchive("../../testdata/crypt.zip")
+	if r.Status != statusUnsupported || len(r.Problems) == 0 {
+		t.Errorf("crypt.zip: %v: %+v", r.Status, r.Problems)
+	}
+	if classify(zipfile.InvalidCompError) != statusUnsupported {
+		t.Errorf("InvalidCompError is %v", classify(zipfile.InvalidCompError))
+	}
+	fmt.Printf("TestCheckOddArchives fini\n")
+}
@@ -0,0 +1,325 @@
+// zipdiff.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// zipdiff compares two archives entry by entry, for when a release artifact
+// changed and nobody knows why.  Entries only in one archive are listed, and
+// for entries in both whatever differs of size, CRC, time, method, mode and
+// comment.  With -u changed text entries get a unified diff as well.
+//
+//	zipdiff [-q] [-u] [-U lines] [-content] [-ignore-mtime] [-ignore-method]
+//	        old.zip new.zip
+//
+// Exit status follows diff: 0 no differences, 1 some, 2 trouble.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hotei/go-zipfile"
+)
+
+const (
+	exitSame    = 0
+	exitDiffer  = 1
+	exitTrouble = 2
+	maxDiffCell = 1 << 24 // biggest lines(old) * lines(new) we'll diff
+)
+
+// zipdiff is one run of the command
+type zipdiff struct {
+	quiet, unified bool
+	context        int
+	opts           zipfile.DiffOptions
+	stdout         io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run parses args and does the work, returning the exit status
+func run(args []string, stdout, stderr io.Writer) int {
+	z := &zipdiff{stdout: stdout}
+	fs := flag.NewFlagSet("zipdiff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: zipdiff [-q] [-u] [-U lines] [-content] [-ignore-mtime] [-ignore-method] old.zip new.zip\n")
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&z.quiet, "q", false, "only say whether the archives differ")
+	fs.BoolVar(&z.unified, "u", false, "show a unified diff of changed text entries")
+	fs.IntVar(&z.context, "U", 3, "lines of context for -u")
+	fs.BoolVar(&z.opts.Content, "content", false, "compare contents, not just sizes and CRCs")
+	fs.BoolVar(&z.opts.IgnoreMtime, "ignore-mtime", false, "don't count time stamp differences")
+	fs.BoolVar(&z.opts.IgnoreMethod, "ignore-method", false, "don't count compression method differences")
+	if err := fs.Parse(args); err != nil {
+		return exitTrouble
+	}
+	if fs.NArg() != 2 || z.context < 0 {
+		fs.Usage()
+		return exitTrouble
+	}
+	oldName, newName := fs.Arg(0), fs.Arg(1)
+	from, err := openArchive(oldName)
+	if err != nil {
+		fmt.Fprintf(stderr, "zipdiff: %s: %v\n", oldName, err)
+		return exitTrouble
+	}
+	to, err := openArchive(newName)
+	if err != nil {
+		fmt.Fprintf(stderr, "zipdiff: %s: %v\n", newName, err)
+		return exitTrouble
+	}
+	diffs, err := zipfile.Diff(from, to, &z.opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "zipdiff: %v\n", err)
+		return exitTrouble
+	}
+	if len(diffs) == 0 {
+		return exitSame
+	}
+	if z.quiet {
+		fmt.Fprintf(stdout, "Archives %s and %s differ\n", oldName, newName)
+		return exitDiffer
+	}
+	for _, d := range diffs {
+		switch d.Kind {
+		case zipfile.DiffRemoved:
+			fmt.Fprintf(stdout, "Only in %s: %s\n", oldName, d.Name)
+		case zipfile.DiffAdded:
+			fmt.Fprintf(stdout, "Only in %s: %s\n", newName, d.Name)
+		case zipfile.DiffChanged:
+			fmt.Fprintf(stdout, "Changed: %s (%s)\n", d.Name, describe(d))
+			if z.unified && (contains(d.Changes, zipfile.ChangedCrc) || contains(d.Changes, zipfile.ChangedContent)) {
+				if err := z.diffEntry(oldName+"!"+d.Name, newName+"!"+d.Name, d); err != nil {
+					fmt.Fprintf(stderr, "zipdiff: %s: %v\n", d.Name, err)
+				}
+			}
+		}
+	}
+	return exitDiffer
+}
+
+func openArchive(name string) (*zipfile.ZipReader, error) {
+	f, err := os.Open(name) // left open, the headers read from it
+	if err != nil {
+		return nil, err
+	}
+	zipfile.Paranoid = false
+	return zipfile.NewReader(f)
+}
+
+// describe spells out an entry's changes, "size 10 -> 12, mode ..."
+func describe(d zipfile.EntryDiff) string {
+	a, b := d.Old, d.New
+	var parts []string
+	for _, c := range d.Changes {
+		var from, to string
+		switch c {
+		case zipfile.ChangedSize:
+			from, to = fmt.Sprint(a.Size), fmt.Sprint(b.Size)
+		case zipfile.ChangedCrc:
+			from, to = fmt.Sprintf("%08x", a.StoredCrc32), fmt.Sprintf("%08x", b.StoredCrc32)
+		case zipfile.ChangedMtime:
+			from, to = a.ModTime().Format(time.RFC3339), b.ModTime().Format(time.RFC3339)
+		case zipfile.ChangedMethod:
+			from, to = zipfile.MethodName(a.Compress), zipfile.MethodName(b.Compress)
+		case zipfile.ChangedMode:
+			from, to = a.Mode().String(), b.Mode().String()
+		case zipfile.ChangedComment:
+			from, to = fmt.Sprintf("%q", a.Comment), fmt.Sprintf("%q", b.Comment)
+		default:
+			parts = append(parts, c)
+			continue
+		}
+		parts = append(parts, c+" "+from+" -> "+to)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// diffEntry prints a unified diff of a changed entry, if both sides are text
+func (z *zipdiff) diffEntry(oldLabel, newLabel string, d zipfile.EntryDiff) error {
+	a, err := readAll(d.Old)
+	if err != nil {
+		return err
+	}
+	b, err := readAll(d.New)
+	if err != nil {
+		return err
+	}
+	if !isText(a) || !isText(b) {
+		fmt.Fprintf(z.stdout, "Binary entries %s and %s differ\n", oldLabel, newLabel)
+		return nil
+	}
+	unified(z.stdout, oldLabel, newLabel, splitLines(a), splitLines(b), z.context)
+	return nil
+}
+
+func readAll(h *zipfile.Header) ([]byte, error) {
+	rdr, err := h.Open()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(rdr)
+}
+
+// isText is true for UTF-8 without NULs, near enough what diff and grep go by
+func isText(data []byte) bool {
+	return bytes.IndexByte(data, 0) < 0 && utf8.Valid(data)
+}
+
+// splitLines cuts data after each newline, the last line may lack one
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		n := bytes.IndexByte(data, '\n') + 1
+		if n == 0 {
+			n = len(data)
+		}
+		lines = append(lines, string(data[:n]))
+		data = data[n:]
+	}
+	return lines
+}
+
+// edit is one line of the edit script, ' ' both, '-' old only, '+' new only
+type edit struct {
+	op   byte
+	line string
+}
+
+// editScript finds a shortest edit script by longest common subsequence.
+// Quadratic, which is why callers check maxDiffCell first.
+func editScript(a, b []string) []edit {
+	n, m := len(a), len(b)
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var script []edit
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			script = append(script, edit{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			script = append(script, edit{'-', a[i]})
+			i++
+		default:
+			script = append(script, edit{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		script = append(script, edit{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		script = append(script, edit{'+', b[j]})
+	}
+	return script
+}
+
+// unified writes a diff -u style diff of a and b
+func unified(w io.Writer, oldLabel, newLabel string, a, b []string, context int) {
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", oldLabel, newLabel)
+	if int64(len(a))*int64(len(b)) > maxDiffCell {
+		fmt.Fprintf(w, "@@ too many lines to diff (%d and %d) @@\n", len(a), len(b))
+		return
+	}
+	script := editScript(a, b)
+	// lines of each side used up before each edit
+	aPos := make([]int, len(script)+1)
+	bPos := make([]int, len(script)+1)
+	for k, e := range script {
+		aPos[k+1], bPos[k+1] = aPos[k], bPos[k]
+		if e.op != '+' {
+			aPos[k+1]++
+		}
+		if e.op != '-' {
+			bPos[k+1]++
+		}
+	}
+	for k := 0; k < len(script); {
+		if script[k].op == ' ' {
+			k++
+			continue
+		}
+		// a hunk runs from context lines before this change to context
+		// lines after the last change that is close enough to join it
+		start := k - context
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for end < len(script) {
+			next := end + 1
+			for next < len(script) && script[next].op == ' ' {
+				next++
+			}
+			if next == len(script) || next-end-1 > 2*context {
+				break
+			}
+			end = next
+		}
+		stop := end + 1 + context
+		if stop > len(script) {
+			stop = len(script)
+		}
+		oldLen, newLen := aPos[stop]-aPos[start], bPos[stop]-bPos[start]
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(aPos[start], oldLen), hunkRange(bPos[start], newLen))
+		for _, e := range script[start:stop] {
+			fmt.Fprintf(w, "%c%s", e.op, e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				fmt.Fprintf(w, "\n\\ No newline at end of file\n")
+			}
+		}
+		k = stop
+	}
+}
+
+// hunkRange is the "start,count" of a hunk header, lines counted from 1
+func hunkRange(before, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
@@ -0,0 +1,129 @@
+// zipdiff_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hotei/go-zipfile"
+)
+
+// numbered returns "line 1\n" up to "line n\n"
+func numbered(n int) string {
+	var s string
+	for i := 1; i <= n; i++ {
+		s += fmt.Sprintf("line %d\n", i)
+	}
+	return s
+}
+
+// Purpose: hunks, context and the missing final newline come out as diff -u
+// writes them
+func TestUnified(t *testing.T) {
+	fmt.Printf("TestUnified start\n")
+	a := numbered(20)
+	b := strings.Replace(a, "line 2\n", "line two\n", 1)
+	b = strings.Replace(b, "line 10\n", "", 1)
+	b += "line 21"
+	tests := []struct {
+		context int
+		want    string
+	}{
+		{3, "--- a\n+++ b\n" +
+			"@@ -1,5 +1,5 @@\n line 1\n-line 2\n+line two\n line 3\n line 4\n line 5\n" +
+			"@@ -7,7 +7,6 @@\n line 7\n line 8\n line 9\n-line 10\n line 11\n line 12\n line 13\n" +
+			"@@ -18,3 +17,4 @@\n line 18\n line 19\n line 20\n+line 21\n\\ No newline at end of file\n"},
+		{1, "--- a\n+++ b\n" +
+			"@@ -1,3 +1,3 @@\n line 1\n-line 2\n+line two\n line 3\n" +
+			"@@ -9,3 +9,2 @@\n line 9\n-line 10\n line 11\n" +
+			"@@ -20 +19,2 @@\n line 20\n+line 21\n\\ No newline at end of file\n"},
+		{0, "--- a\n+++ b\n" +
+			"@@ -2 +2 @@\n-line 2\n+line two\n" +
+			"@@ -10 +9,0 @@\n-line 10\n" +
+			"@@ -20,0 +20 @@\n+line 21\n\\ No newline at end of file\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		unified(&buf, "a", "b", splitLines([]byte(a)), splitLines([]byte(b)), tt.context)
+		if buf.String() != tt.want {
+			t.Errorf("context %d: got\n%s\nexpected\n%s", tt.context, buf.String(), tt.want)
+		}
+	}
+	fmt.Printf("TestUnified fini\n")
+}
+
+// writeArchive makes a deflated archive of name, contents pairs
+func writeArchive(t *testing.T, name string, entries ...string) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	w := zipfile.NewWriter(f)
+	for i := 0; i < len(entries); i += 2 {
+		h := &zipfile.Header{Name: entries[i], Compress: zipfile.ZIP_DEFLATED,
+			Mtime: time.Date(2012, 3, 4, 5, 6, 8, 0, time.UTC)}
+		fw, err := w.Create(h)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		io.WriteString(fw, entries[i+1])
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// Purpose: the exit status and report for the same, different and missing
+// archives
+func TestRun(t *testing.T) {
+	fmt.Printf("TestRun start\n")
+	dir, err := ioutil.TempDir("", "zipdiff")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	oldZip, newZip := filepath.Join(dir, "old.zip"), filepath.Join(dir, "new.zip")
+	writeArchive(t, oldZip, "a.txt", "one\ntwo\n", "b.bin", "\x00\x01", "gone.txt", "bye\n")
+	writeArchive(t, newZip, "a.txt", "one\n2\n", "b.bin", "\x00\x02", "new.txt", "hi\n")
+
+	var stdout, stderr bytes.Buffer
+	if rc := run([]string{"../../testdata/unix.zip", "../../testdata/unix.zip"}, &stdout, &stderr); rc != exitSame || stdout.Len() != 0 {
+		t.Errorf("same archive: exit %d, output %q", rc, stdout.String())
+	}
+	if rc := run([]string{"-q", oldZip, newZip}, &stdout, &stderr); rc != exitDiffer {
+		t.Errorf("-q: exit %d, expected %d", rc, exitDiffer)
+	}
+	stdout.Reset()
+	if rc := run([]string{"-u", oldZip, newZip}, &stdout, &stderr); rc != exitDiffer {
+		t.Errorf("-u: exit %d, expected %d", rc, exitDiffer)
+	}
+	for _, want := range []string{
+		"Changed: a.txt (size 8 -> 6, crc ",
+		"--- " + oldZip + "!a.txt\n+++ " + newZip + "!a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n",
+		"Binary entries " + oldZip + "!b.bin and " + newZip + "!b.bin differ\n",
+		"Only in " + oldZip + ": gone.txt\n",
+		"Only in " + newZip + ": new.txt\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("%q missing from\n%s", want, stdout.String())
+		}
+	}
+	if rc := run([]string{oldZip, filepath.Join(dir, "none.zip")}, &stdout, &stderr); rc != exitTrouble {
+		t.Errorf("missing archive: exit %d, expected %d", rc, exitTrouble)
+	}
+	fmt.Printf("TestRun fini\n")
+}
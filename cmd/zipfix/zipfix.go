@@ -0,0 +1,267 @@
+// zipfix.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// zipfix rebuilds an archive whose central directory is missing, truncated
+// or wrong, much like "zip -FF".  It scans the whole file for local headers
+// (Carve does the scanning, so it resyncs after damaged stretches), keeps
+// every entry whose data decompresses and matches its CRC, and copies their
+// compressed data as it is to a new archive with a fresh central directory.
+// Encrypted entries can't be checked without the password, they are kept
+// still encrypted when their sizes agree with the data descriptor and the
+// next header starts where they end.  Anything dropped is logged to stderr
+// with the reason.
+//
+//	zipfix [-v] broken.zip fixed.zip
+//
+// The new archive is written to a temporary file beside fixed.zip and
+// renamed into place at the end.  fixed.zip can't be broken.zip itself.
+//
+// Exit status is 0 if every entry found was kept, 1 if some were dropped
+// and 2 if no archive could be written.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hotei/go-zipfile"
+)
+
+var flagVerbose = flag.Bool("v", false, "log every entry kept as well as those dropped")
+
+var sameFileError = errors.New("output is the archive being fixed")
+
+// dropped is an entry left out of the rebuilt archive
+type dropped struct {
+	Name   string
+	Offset int64
+	Reason string
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("zipfix: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zipfix [-v] broken.zip fixed.zip\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	zipfile.Paranoid = false // a broken archive is the whole point
+	in, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		fatal(err)
+	}
+	kept, drops, err := fixTo(flag.Arg(1), in, fi)
+	if err != nil {
+		fatal(err)
+	}
+	for _, d := range drops {
+		log.Printf("dropped %q at offset %d: %s", d.Name, d.Offset, d.Reason)
+	}
+	log.Printf("%s: kept %d entries, dropped %d", flag.Arg(1), len(kept), len(drops))
+	if len(drops) > 0 {
+		os.Exit(1)
+	}
+}
+
+func fatal(err error) {
+	log.Print(err)
+	os.Exit(2)
+}
+
+// fixTo writes the rebuilt archive to a temporary file in out's directory
+// and renames it to out if that worked.  An out that is the broken archive
+// itself is refused before anything is written, rebuilding in place would
+// truncate what is being rescued.
+func fixTo(out string, in *os.File, fi os.FileInfo) ([]string, []dropped, error) {
+	if ofi, err := os.Stat(out); err == nil && os.SameFile(ofi, fi) {
+		return nil, nil, fmt.Errorf("%s: %v", out, sameFileError)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(out), "zipfix")
+	if err != nil {
+		return nil, nil, err
+	}
+	kept, drops, err := fix(in, fi.Size(), tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644) // TempFile makes it 0600
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), out)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return kept, drops, err
+}
+
+// fix writes every good entry found in r to w, returning the names kept and
+// what was dropped
+func fix(r io.ReaderAt, size int64, w io.Writer) ([]string, []dropped, error) {
+	res, err := zipfile.Carve(r, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	var found []*zipfile.CarvedEntry
+	for _, a := range res.Archives {
+		found = append(found, a.Entries...)
+	}
+	found = append(found, res.Orphans...)
+	sort.Sort(byOffset(found))
+
+	zw := zipfile.NewWriter(w)
+	var kept []string
+	var drops []dropped
+	names := make(map[string]bool)
+	covered := int64(-1) // end of the last entry kept, headers before it are inside its data
+	for _, ce := range found {
+		h := ce.Header
+		drop := func(reason string) {
+			drops = append(drops, dropped{h.Name, ce.Offset, reason})
+		}
+		switch {
+		case ce.Offset < covered:
+			continue // signature that happened to be inside another entry's data
+		case ce.Confidence < 1 && !(h.Encrypted() && chained(r, size, ce)):
+			drop(reason(h))
+			continue
+		case names[h.Name]:
+			drop("duplicate name")
+			continue
+		}
+		src, err := h.OpenRaw()
+		if err != nil {
+			drop(err.Error())
+			continue
+		}
+		if err := copyEntry(zw, h, src); err != nil {
+			return kept, drops, err
+		}
+		if *flagVerbose && ce.Confidence < 1 {
+			log.Printf("kept %q, encrypted so its data wasn't checked", h.Name)
+		} else if *flagVerbose {
+			log.Printf("kept %q", h.Name)
+		}
+		names[h.Name] = true
+		kept = append(kept, h.Name)
+		covered = ce.Offset + h.Offset - h.LocalOffset + h.SizeCompr
+	}
+	return kept, drops, zw.Close()
+}
+
+// chained reports whether an encrypted entry's sizes hold up: a data
+// descriptor, if it has one, that agrees with the header, then another
+// header or the end of the file.  It's as close to checking the data as we
+// can get without the password.
+func chained(r io.ReaderAt, size int64, ce *zipfile.CarvedEntry) bool {
+	h := ce.Header
+	off, err := h.DataOffset()
+	if err != nil || h.SizeCompr < 12 { // not even room for the encryption header
+		return false
+	}
+	end := ce.Offset + off - h.LocalOffset + h.SizeCompr
+	if h.Flags&8 != 0 { // sizes repeated in a data descriptor
+		desc := make([]byte, 16)
+		n, _ := r.ReadAt(desc, end)
+		desc = desc[:n]
+		if len(desc) >= 4 && string(desc[:4]) == zipfile.ZIP_DataDescSig { // signature is optional
+			desc, end = desc[4:], end+4
+		}
+		if len(desc) < zipfile.DataDescSize || binary.LittleEndian.Uint32(desc[0:4]) != h.StoredCrc32 ||
+			int64(binary.LittleEndian.Uint32(desc[4:8])) != h.SizeCompr ||
+			int64(binary.LittleEndian.Uint32(desc[8:12])) != h.Size {
+			return false
+		}
+		end += zipfile.DataDescSize
+	}
+	if end == size {
+		return true
+	}
+	sig := make([]byte, 4)
+	if _, err := r.ReadAt(sig, end); err != nil {
+		return false
+	}
+	switch string(sig) {
+	case zipfile.ZIP_LocalHdrSig, zipfile.ZIP_CentDirSig, zipfile.ZIP_EndCentDirSig:
+		return true
+	}
+	return false
+}
+
+// reason explains why Carve didn't trust an entry
+func reason(h *zipfile.Header) string {
+	if h.Encrypted() {
+		return "encrypted, and its sizes don't agree with what follows it"
+	}
+	if h.Compress != zipfile.ZIP_STORED && h.Compress != zipfile.ZIP_DEFLATED {
+		return "unsupported compression method " + zipfile.MethodName(h.Compress)
+	}
+	if _, err := readAll(h); err != nil {
+		return err.Error()
+	}
+	return "data could not be verified"
+}
+
+func readAll(h *zipfile.Header) ([]byte, error) {
+	rdr, err := h.Open()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(rdr)
+}
+
+// copyEntry adds a salvaged entry to zw, keeping what its header knows.  The
+// compressed data in src goes in untouched, Carve has already checked it
+// against the CRC and sizes (or chained has, for an encrypted entry).  Extra
+// fields come along, the Writer drops any zip64 field and adds its own.
+func copyEntry(zw *zipfile.Writer, h *zipfile.Header, src io.Reader) error {
+	nh := &zipfile.Header{
+		Name:          h.Name,
+		Mtime:         h.Mtime,
+		Compress:      h.Compress,
+		StoredCrc32:   h.StoredCrc32,
+		Size:          h.Size,
+		VersionMadeBy: h.VersionMadeBy,
+		ExternalAttrs: h.ExternalAttrs,
+		InternalAttrs: h.InternalAttrs,
+		Flags:         h.Flags,
+		Extra:         h.Extra,
+		Comment:       h.Comment,
+	}
+	ew, err := zw.CreateRaw(nh)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(ew, src)
+	return err
+}
+
+type byOffset []*zipfile.CarvedEntry
+
+func (b byOffset) Len() int           { return len(b) }
+func (b byOffset) Less(i, j int) bool { return b[i].Offset < b[j].Offset }
+func (b byOffset) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,261 @@
+// zipfix_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hotei/go-zipfile"
+)
+
+// Purpose: an archive with half its directory gone and one damaged entry
+// comes back with everything but the damaged entry, compressed data copied
+// byte for byte
+func TestFix(t *testing.T) {
+	fmt.Printf("TestFix start\n")
+	orig, err := ioutil.ReadFile("../../testdata/unix.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	f, err := os.Open("../../testdata/unix.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := zipfile.NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := make(map[string][]byte)
+	wantRaw := make(map[string][]byte)
+	for _, h := range filelist {
+		data, err := readAll(h)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		want[h.Name] = data
+		wantRaw[h.Name] = readRaw(t, h)
+	}
+	readme := filelist[1]
+	if readme.Name != "unix/readme.txt" {
+		t.Fatalf("unexpected entry %s", readme.Name)
+	}
+	off, err := readme.DataOffset()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	broken := append([]byte(nil), orig...)
+	broken[off] ^= 0xff
+	broken = broken[:bytes.Index(broken, []byte("PK\001\002"))+50]
+
+	var fixed bytes.Buffer
+	kept, drops, err := fix(bytes.NewReader(broken), int64(len(broken)), &fixed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(kept) != 4 || len(drops) != 1 || drops[0].Name != readme.Name {
+		t.Fatalf("kept %v, dropped %+v", kept, drops)
+	}
+
+	rz, err = zipfile.NewReader(bytes.NewReader(fixed.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err = rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(filelist) != 4 {
+		t.Fatalf("fixed archive has %d entries", len(filelist))
+	}
+	for _, h := range filelist {
+		data, err := readAll(h)
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", h.Name, err)
+		}
+		if !bytes.Equal(data, want[h.Name]) {
+			t.Errorf("%s: got %q, expected %q", h.Name, data, want[h.Name])
+		}
+		if !bytes.Equal(readRaw(t, h), wantRaw[h.Name]) {
+			t.Errorf("%s: compressed data differs from the original's", h.Name)
+		}
+	}
+
+	// deflated at level 1, recompressing would change the bytes
+	var fast bytes.Buffer
+	zw := zipfile.NewWriter(&fast)
+	zw.RegisterCompressor(zipfile.ZIP_DEFLATED, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, flate.BestSpeed)
+	})
+	fw, err := zw.Create(&zipfile.Header{Name: "fast.txt", Compress: zipfile.ZIP_DEFLATED})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(fw, "%d squared is %d\n", i, i*i)
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fixed.Reset()
+	if _, _, err = fix(bytes.NewReader(fast.Bytes()), int64(fast.Len()), &fixed); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Equal(fixed.Bytes(), fast.Bytes()) {
+		t.Errorf("undamaged deflated archive changed")
+	}
+	fmt.Printf("TestFix fini\n")
+}
+
+// Purpose: encrypted entries can't be checked against their CRC, they are
+// kept as they were when their descriptors and the next header line up and
+// dropped as encrypted when they don't.  Extra fields survive the copy.
+func TestFixEncrypted(t *testing.T) {
+	fmt.Printf("TestFixEncrypted start\n")
+	orig, err := ioutil.ReadFile("../../testdata/crypt.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var fixed bytes.Buffer
+	kept, drops, err := fix(bytes.NewReader(orig), int64(len(orig)), &fixed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(kept) != 3 || len(drops) != 0 {
+		t.Fatalf("kept %v, dropped %+v", kept, drops)
+	}
+	rz, err := zipfile.NewReader(bytes.NewReader(fixed.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rz.SetPassword("swordfish")
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, h := range filelist {
+		if _, err := readAll(h); err != nil {
+			t.Errorf("%s: Unexpected error: %v", h.Name, err)
+		}
+		if h.Name == "secret.txt" && !h.Encrypted() {
+			t.Errorf("%s: no longer encrypted", h.Name)
+		}
+	}
+
+	// a descriptor that disagrees with the header, the sizes can't be trusted
+	broken := append([]byte(nil), orig...)
+	broken[bytes.Index(broken, []byte("PK\007\010"))+4] ^= 0xff
+	fixed.Reset()
+	kept, drops, err = fix(bytes.NewReader(broken), int64(len(broken)), &fixed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(kept) != 2 || len(drops) != 1 || !strings.HasPrefix(drops[0].Reason, "encrypted") {
+		t.Fatalf("kept %v, dropped %+v", kept, drops)
+	}
+
+	// extended timestamp, the DOS time alone would be off by the zone
+	var withExtra bytes.Buffer
+	zw := zipfile.NewWriter(&withExtra)
+	mtime := time.Date(2011, 3, 4, 5, 6, 7, 0, time.UTC)
+	extra := []byte{0x55, 0x54, 5, 0, 1, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(extra[5:], uint32(mtime.Unix()))
+	fw, err := zw.Create(&zipfile.Header{Name: "stamped.txt", Mtime: mtime, Extra: extra})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fmt.Fprintf(fw, "stamped\n")
+	if err = zw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fixed.Reset()
+	if _, _, err = fix(bytes.NewReader(withExtra.Bytes()), int64(withExtra.Len()), &fixed); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rz, err = zipfile.NewReader(bytes.NewReader(fixed.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err = rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(filelist) != 1 || !bytes.Equal(filelist[0].Extra, extra) || !filelist[0].ModTime().Equal(mtime) {
+		t.Errorf("extra fields not kept: %+v", filelist)
+	}
+	fmt.Printf("TestFixEncrypted fini\n")
+}
+
+// readRaw is the entry's data as stored
+func readRaw(t *testing.T, h *zipfile.Header) []byte {
+	src, err := h.OpenRaw()
+	if err != nil {
+		t.Fatalf("%s: Unexpected error: %v", h.Name, err)
+	}
+	data, err := ioutil.ReadAll(src)
+	if err != nil {
+		t.Fatalf("%s: Unexpected error: %v", h.Name, err)
+	}
+	return data
+}
+
+// Purpose: the broken archive can't also be the output, and a good run
+// leaves only the fixed archive behind
+func TestFixTo(t *testing.T) {
+	fmt.Printf("TestFixTo start\n")
+	dir, err := ioutil.TempDir("", "zipfix")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	orig, err := ioutil.ReadFile("../../testdata/unix.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	broken := filepath.Join(dir, "broken.zip")
+	if err = ioutil.WriteFile(broken, orig, 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	in, err := os.Open(broken)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, _, err = fixTo(broken, in, fi); err == nil || !strings.Contains(err.Error(), sameFileError.Error()) {
+		t.Errorf("expected sameFileError, got %v", err)
+	}
+	if data, _ := ioutil.ReadFile(broken); !bytes.Equal(data, orig) {
+		t.Errorf("refused run changed the input")
+	}
+	fixed := filepath.Join(dir, "fixed.zip")
+	if kept, _, err := fixTo(fixed, in, fi); err != nil || len(kept) != 5 {
+		t.Fatalf("kept %v, %v", kept, err)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, "*")); len(names) != 2 {
+		t.Errorf("expected broken.zip and fixed.zip, got %v", names)
+	}
+	fmt.Printf("TestFixTo fini\n")
+}
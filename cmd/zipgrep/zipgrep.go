@@ -0,0 +1,138 @@
+// zipgrep.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// zipgrep searches the entries of a zip archive for a regular expression,
+// like Info-ZIP's zipgrep but without an unzip and an egrep per entry.
+// Matching lines are printed as entry:line:text, binary entries that match
+// get a line saying so.  Globs after the archive name (** for any depth)
+// search only the entries matching them, globs after -x skip entries.  With
+// -r archives inside the archive are searched too, their entries named
+// lib/a.jar!/path.
+//
+//	zipgrep [-i] [-l] [-r] [-j workers] regexp archive.zip [glob ...] [-x glob ...]
+//
+// Exit status follows grep: 0 if something matched, 1 if not, 2 for trouble.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/hotei/go-zipfile"
+)
+
+const (
+	exitMatch   = 0
+	exitNone    = 1
+	exitTrouble = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run parses args and does the work, returning the exit status
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("zipgrep", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: zipgrep [-i] [-l] [-r] [-j workers] regexp archive.zip [glob ...] [-x glob ...]\n")
+		fs.PrintDefaults()
+	}
+	ignoreCase := fs.Bool("i", false, "ignore case")
+	namesOnly := fs.Bool("l", false, "print only the names of entries that match")
+	nested := fs.Bool("r", false, "search archives inside the archive too")
+	workers := fs.Int("j", 0, "entries to search at once, 0 for one per CPU")
+	if err := fs.Parse(args); err != nil {
+		return exitTrouble
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return exitTrouble
+	}
+	expr := fs.Arg(0)
+	if *ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		fmt.Fprintf(stderr, "zipgrep: %v\n", err)
+		return exitTrouble
+	}
+	sel, err := selector(fs.Args()[2:])
+	if err != nil {
+		fmt.Fprintf(stderr, "zipgrep: %v\n", err)
+		return exitTrouble
+	}
+	name := fs.Arg(1)
+	f, err := os.Open(name)
+	if err != nil {
+		fmt.Fprintf(stderr, "zipgrep: %v\n", err)
+		return exitTrouble
+	}
+	defer f.Close()
+	rz, err := zipfile.NewReader(f)
+	if err != nil {
+		fmt.Fprintf(stderr, "zipgrep: %s: %v\n", name, err)
+		return exitTrouble
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	status := exitNone
+	last := ""
+	opts := &zipfile.GrepOptions{Selector: sel, Nested: *nested, Workers: *workers}
+	err = rz.Grep(re, opts, func(m zipfile.GrepMatch) error {
+		status = exitMatch
+		switch {
+		case *namesOnly:
+			if m.Path != last {
+				fmt.Fprintf(out, "%s\n", m.Path)
+			}
+		case m.Binary:
+			fmt.Fprintf(out, "Binary entry %s matches\n", m.Path)
+		default:
+			fmt.Fprintf(out, "%s:%d:%s\n", m.Path, m.Line, m.Text)
+		}
+		last = m.Path
+		return nil
+	})
+	if err != nil {
+		out.Flush()
+		fmt.Fprintf(stderr, "zipgrep: %s: %v\n", name, err)
+		return exitTrouble
+	}
+	return status
+}
+
+// selector makes a Selector of the globs after the archive name, those after
+// -x excluding
+func selector(args []string) (*zipfile.Selector, error) {
+	sel := new(zipfile.Selector)
+	excluding := false
+	for _, arg := range args {
+		if arg == "-x" {
+			excluding = true
+			continue
+		}
+		g, err := zipfile.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("bad glob %q: %v", arg, err)
+		}
+		if excluding {
+			sel.Exclude = append(sel.Exclude, g)
+		} else {
+			sel.Include = append(sel.Include, g)
+		}
+	}
+	return sel, nil
+}
@@ -0,0 +1,39 @@
+// zipgrep_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// Purpose: output, globs and exit status on unix.zip
+func TestZipgrep(t *testing.T) {
+	fmt.Printf("TestZipgrep start\n")
+	const archive = "../../testdata/unix.zip"
+	tests := []struct {
+		args []string
+		rc   int
+		want string
+	}{
+		{[]string{"echo", archive}, exitMatch, "unix/bin/hello.sh:2:echo hello\n"},
+		{[]string{"-i", "ECHO|PLAIN", archive}, exitMatch, "unix/readme.txt:1:plain text file\nunix/bin/hello.sh:2:echo hello\n"},
+		{[]string{"-l", "e", archive, "**", "-x", "*.sh"}, exitMatch, "unix/readme.txt\n"}, // link.txt is a symlink, not searched
+		{[]string{"echo", archive, "*.txt"}, exitNone, ""},
+		{[]string{"(", archive}, exitTrouble, ""},
+		{[]string{"x", "../../testdata/none.zip"}, exitTrouble, ""},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if rc := run(tt.args, &stdout, &stderr); rc != tt.rc || stdout.String() != tt.want {
+			t.Errorf("%v: exit %d, output %q, expected %d and %q", tt.args, rc, stdout.String(), tt.rc, tt.want)
+		}
+	}
+	fmt.Printf("TestZipgrep fini\n")
+}
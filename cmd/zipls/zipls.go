@@ -0,0 +1,433 @@
+// zipls.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// zipls lists the contents of zip archives.  The output imitates
+// "unzip -l" (the default), "unzip -v" or zipinfo, or is JSON for scripts.
+// Entries come from the central directory unless -local is given, in which
+// case the local headers are read front to back, which still works when the
+// directory at the end of the archive is missing or damaged.
+//
+// -i and -x list only the entries matching, or not matching, a glob where **
+// stands for any number of directories.  Both can be given more than once.
+//
+//	zipls [-l | -v | -z | -json] [-local] [-i glob] [-x glob] archive.zip ...
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hotei/go-zipfile"
+)
+
+var (
+	flagShort   = flag.Bool("l", false, "list like unzip -l (the default)")
+	flagVerbose = flag.Bool("v", false, "list like unzip -v")
+	flagInfo    = flag.Bool("z", false, "list like zipinfo")
+	flagJSON    = flag.Bool("json", false, "list as JSON")
+	flagLocal   = flag.Bool("local", false, "scan local headers instead of reading the central directory")
+	selector    zipfile.Selector // set by -i and -x
+)
+
+func init() {
+	flag.Var((*globList)(&selector.Include), "i", "list only entries matching this glob")
+	flag.Var((*globList)(&selector.Exclude), "x", "don't list entries matching this glob")
+}
+
+// globList is a flag that can be given more than once, each a Glob
+type globList []zipfile.Pattern
+
+func (g *globList) String() string {
+	return fmt.Sprint(*g)
+}
+
+func (g *globList) Set(s string) error {
+	p, err := zipfile.Glob(s)
+	if err != nil {
+		return err
+	}
+	*g = append(*g, p)
+	return nil
+}
+
+// archive is one listed zip file
+type archive struct {
+	Name    string
+	Size    int64
+	Headers []*zipfile.Header
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zipls [-l | -v | -z | -json] [-local] [-i glob] [-x glob] archive.zip ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	list := listShort
+	switch {
+	case *flagVerbose:
+		list = listVerbose
+	case *flagInfo:
+		list = listInfo
+	}
+	status := 0
+	var archives []*archive
+	for _, name := range flag.Args() {
+		a, err := readArchive(name, *flagLocal)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "zipls: %s: %v\n", name, err)
+			status = 1
+			continue
+		}
+		if *flagJSON {
+			archives = append(archives, a)
+			continue
+		}
+		list(os.Stdout, a)
+	}
+	if *flagJSON {
+		if err := listJSON(os.Stdout, archives); err != nil {
+			fmt.Fprintf(os.Stderr, "zipls: %v\n", err)
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+// readArchive gets the headers of every entry in the named archive
+func readArchive(name string, local bool) (*archive, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	rz, err := zipfile.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var hdrs []*zipfile.Header
+	if local {
+		hdrs, err = rz.Headers()
+	} else {
+		hdrs, err = rz.CentralHeaders()
+	}
+	if err != nil {
+		return nil, err
+	}
+	var picked []*zipfile.Header
+	for _, h := range hdrs {
+		if selector.Match(h) {
+			picked = append(picked, h)
+		}
+	}
+	return &archive{Name: name, Size: fi.Size(), Headers: picked}, nil
+}
+
+// listShort prints what "unzip -l" would
+func listShort(w io.Writer, a *archive) {
+	fmt.Fprintf(w, "Archive:  %s\n", a.Name)
+	fmt.Fprintf(w, "  Length      Date    Time    Name\n")
+	fmt.Fprintf(w, "---------  ---------- -----   ----\n")
+	var total int64
+	for _, h := range a.Headers {
+		fmt.Fprintf(w, "%9d  %s   %s\n", h.Size, h.ModTime().Format("2006-01-02 15:04"), h.Name)
+		total += h.Size
+	}
+	fmt.Fprintf(w, "---------                     -------\n")
+	fmt.Fprintf(w, "%9d                     %s\n", total, fileCount(len(a.Headers)))
+}
+
+// listVerbose prints what "unzip -v" would
+func listVerbose(w io.Writer, a *archive) {
+	fmt.Fprintf(w, "Archive:  %s\n", a.Name)
+	fmt.Fprintf(w, " Length   Method    Size  Cmpr    Date    Time   CRC-32   Name\n")
+	fmt.Fprintf(w, "--------  ------  ------- ---- ---------- ----- --------  ----\n")
+	var total, totalCompr int64
+	for _, h := range a.Headers {
+		fmt.Fprintf(w, "%8d  %-6s %8d %3d%% %s %08x  %s\n",
+			h.Size, unzipMethod(h), h.SizeCompr, ratio(h.Size, h.SizeCompr),
+			h.ModTime().Format("2006-01-02 15:04"), h.StoredCrc32, h.Name)
+		total += h.Size
+		totalCompr += h.SizeCompr
+	}
+	fmt.Fprintf(w, "--------          -------  ---                            -------\n")
+	fmt.Fprintf(w, "%8d         %8d %3d%%                            %s\n",
+		total, totalCompr, ratio(total, totalCompr), fileCount(len(a.Headers)))
+}
+
+// listInfo prints what zipinfo would
+func listInfo(w io.Writer, a *archive) {
+	fmt.Fprintf(w, "Archive:  %s\n", a.Name)
+	fmt.Fprintf(w, "Zip file size: %d bytes, number of entries: %d\n", a.Size, len(a.Headers))
+	var total, totalCompr int64
+	for _, h := range a.Headers {
+		fmt.Fprintf(w, "%-10s %2d.%d %s %8d %s %s %s %s\n",
+			attrString(h), h.VersionMadeBy&0xff/10, h.VersionMadeBy&0xff%10, hostName(h.VersionMadeBy>>8),
+			h.Size, infoFlags(h), infoMethod(h), h.ModTime().Format("06-Jan-02 15:04"), h.Name)
+		total += h.Size
+		totalCompr += h.SizeCompr
+	}
+	pct := 0.0
+	if total > 0 {
+		pct = 100 * (1 - float64(totalCompr)/float64(total))
+	}
+	fmt.Fprintf(w, "%s, %d bytes uncompressed, %d bytes compressed:  %.1f%%\n",
+		fileCount(len(a.Headers)), total, totalCompr, pct)
+}
+
+type jsonEntry struct {
+	Name           string    `json:"name"`
+	Size           int64     `json:"size"`
+	CompressedSize int64     `json:"compressed_size"`
+	Method         string    `json:"method"`
+	CRC32          string    `json:"crc32"`
+	Modified       time.Time `json:"modified"`
+	Mode           string    `json:"mode"`
+	Offset         int64     `json:"offset"`
+	Comment        string    `json:"comment,omitempty"`
+}
+
+type jsonArchive struct {
+	Archive        string      `json:"archive"`
+	Size           int64       `json:"size"`
+	Entries        []jsonEntry `json:"entries"`
+	Files          int         `json:"files"`
+	TotalSize      int64       `json:"total_size"`
+	TotalCompr     int64       `json:"total_compressed_size"`
+	RatioPercent   int         `json:"ratio_percent"`
+	CentralHeaders bool        `json:"central_headers"`
+}
+
+// listJSON prints every archive as one JSON array
+func listJSON(w io.Writer, archives []*archive) error {
+	out := make([]jsonArchive, 0, len(archives))
+	for _, a := range archives {
+		ja := jsonArchive{Archive: a.Name, Size: a.Size, Files: len(a.Headers),
+			Entries: make([]jsonEntry, 0, len(a.Headers)), CentralHeaders: !*flagLocal}
+		for _, h := range a.Headers {
+			ja.Entries = append(ja.Entries, jsonEntry{
+				Name:           h.Name,
+				Size:           h.Size,
+				CompressedSize: h.SizeCompr,
+				Method:         zipfile.MethodName(h.Compress),
+				CRC32:          fmt.Sprintf("%08x", h.StoredCrc32),
+				Modified:       h.ModTime(),
+				Mode:           lsMode(h.Mode()),
+				Offset:         h.LocalOffset,
+				Comment:        h.Comment,
+			})
+			ja.TotalSize += h.Size
+			ja.TotalCompr += h.SizeCompr
+		}
+		ja.RatioPercent = ratio(ja.TotalSize, ja.TotalCompr)
+		out = append(out, ja)
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+func fileCount(n int) string {
+	if n == 1 {
+		return "1 file"
+	}
+	return fmt.Sprintf("%d files", n)
+}
+
+// ratio is the space saved as a percentage, rounded the way unzip does it:
+// to tenths first, then to whole numbers
+func ratio(size, compr int64) int {
+	if size == 0 {
+		return 0
+	}
+	diff, sign := size-compr, 1
+	if diff < 0 {
+		diff, sign = -diff, -1
+	}
+	permille := (1000*diff + size/2) / size
+	return sign * int((permille+5)/10)
+}
+
+// deflate's compression level lives in flag bits 1 and 2
+var deflateLevels = []string{"N", "X", "F", "S"}
+
+// unzipMethod is the method column of "unzip -v"
+func unzipMethod(h *zipfile.Header) string {
+	switch h.Compress {
+	case zipfile.ZIP_STORED:
+		return "Stored"
+	case zipfile.ZIP_DEFLATED:
+		return "Defl:" + deflateLevels[h.Flags>>1&3]
+	case 1:
+		return "Shrunk"
+	case 2, 3, 4, 5:
+		return fmt.Sprintf("Reduce%d", h.Compress-1)
+	case 6:
+		return "Implode"
+	case 9:
+		return "Def64#"
+	case 12:
+		return "BZip2"
+	case 14:
+		return "LZMA"
+	case 93:
+		return "Zstd"
+	case 98:
+		return "PPMd"
+	}
+	return fmt.Sprintf("Unk:%03d", h.Compress)
+}
+
+// infoMethod is the method column of zipinfo
+func infoMethod(h *zipfile.Header) string {
+	switch h.Compress {
+	case zipfile.ZIP_STORED:
+		return "stor"
+	case zipfile.ZIP_DEFLATED:
+		return "def" + deflateLevels[h.Flags>>1&3]
+	case 1:
+		return "shrk"
+	case 2, 3, 4, 5:
+		return fmt.Sprintf("re:%d", h.Compress-1)
+	case 6:
+		dict, trees := 4, 2
+		if h.Flags&2 != 0 {
+			dict = 8
+		}
+		if h.Flags&4 != 0 {
+			trees = 3
+		}
+		return fmt.Sprintf("i%d:%d", dict, trees)
+	case 7:
+		return "tokn"
+	case 9:
+		return "def#"
+	case 12:
+		return "bzp2"
+	case 14:
+		return "lzma"
+	case 93:
+		return "zstd"
+	case 98:
+		return "ppmd"
+	}
+	return fmt.Sprintf("u%03d", h.Compress)
+}
+
+// infoFlags is zipinfo's two letter column: text or binary (upper case when
+// encrypted), then whether there is an extra field and a data descriptor
+func infoFlags(h *zipfile.Header) string {
+	kind := "b"
+	if h.InternalAttrs&1 != 0 {
+		kind = "t"
+	}
+	if h.Flags&1 != 0 {
+		kind = string(kind[0] - 'a' + 'A')
+	}
+	descriptor := h.Flags&8 != 0
+	switch {
+	case len(h.Extra) > 0 && descriptor:
+		return kind + "X"
+	case len(h.Extra) > 0:
+		return kind + "x"
+	case descriptor:
+		return kind + "l"
+	}
+	return kind + "-"
+}
+
+// zipinfo's abbreviations for the "version made by" host system
+var hostNames = []string{"fat", "amg", "vms", "unx", "vcm", "atr", "hpf", "mac", "zzz",
+	"cpm", "t20", "ntf", "qds", "aco", "vft", "mvs", "be ", "nsk", "ths", "osx"}
+
+func hostName(host uint16) string {
+	if int(host) < len(hostNames) {
+		return hostNames[host]
+	}
+	return "???"
+}
+
+// attrString is zipinfo's first column, ls style for unix creators and
+// MSDOS attribute letters for everyone else
+func attrString(h *zipfile.Header) string {
+	switch h.VersionMadeBy >> 8 {
+	case 3, 19: // unix, OS X
+		return lsMode(h.Mode())
+	}
+	dos := h.ExternalAttrs & 0xff
+	b := []byte("-r-----")
+	if dos&0x10 != 0 || h.Typeflag == zipfile.TypeDir {
+		b[0] = 'd'
+	}
+	if dos&0x01 == 0 {
+		b[2] = 'w'
+	}
+	if dos&0x20 != 0 {
+		b[4] = 'a'
+	}
+	if dos&0x02 != 0 {
+		b[5] = 'h'
+	}
+	if dos&0x04 != 0 {
+		b[6] = 's'
+	}
+	return string(b)
+}
+
+// lsMode formats mode the way ls -l does, os.FileMode.String() doesn't
+func lsMode(mode os.FileMode) string {
+	b := []byte("----------")
+	switch {
+	case mode&os.ModeDir != 0:
+		b[0] = 'd'
+	case mode&os.ModeSymlink != 0:
+		b[0] = 'l'
+	case mode&os.ModeNamedPipe != 0:
+		b[0] = 'p'
+	case mode&os.ModeSocket != 0:
+		b[0] = 's'
+	case mode&os.ModeCharDevice != 0:
+		b[0] = 'c'
+	case mode&os.ModeDevice != 0:
+		b[0] = 'b'
+	}
+	const rwx = "rwxrwxrwx"
+	for i := 0; i < 9; i++ {
+		if mode&(1<<uint(8-i)) != 0 {
+			b[i+1] = rwx[i]
+		}
+	}
+	special := func(set bool, pos int, c byte) {
+		if !set {
+			return
+		}
+		if b[pos] == '-' {
+			c -= 'a' - 'A' // set without execute shows upper case
+		}
+		b[pos] = c
+	}
+	special(mode&os.ModeSetuid != 0, 3, 's')
+	special(mode&os.ModeSetgid != 0, 6, 's')
+	special(mode&os.ModeSticky != 0, 9, 't')
+	return string(b)
+}
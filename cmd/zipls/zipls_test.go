@@ -0,0 +1,140 @@
+// zipls_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/hotei/go-zipfile"
+)
+
+// expected output was captured from Info-ZIP unzip 6.0 and zipinfo
+var listings = []struct {
+	list    func(io.Writer, *archive)
+	archive string
+	want    string
+}{
+	{listShort, "unix.zip", `Archive:  ../../testdata/unix.zip
+  Length      Date    Time    Name
+---------  ---------- -----   ----
+        0  2012-01-02 03:04   unix/
+       16  2012-01-02 03:04   unix/readme.txt
+       10  2012-01-02 03:04   unix/link.txt
+        0  2012-01-02 03:04   unix/bin/
+       21  2012-01-02 03:04   unix/bin/hello.sh
+---------                     -------
+       47                     5 files
+`},
+	{listVerbose, "stuf.zip", `Archive:  ../../testdata/stuf.zip
+ Length   Method    Size  Cmpr    Date    Time   CRC-32   Name
+--------  ------  ------- ---- ---------- ----- --------  ----
+     160  Defl:N      100  38% 2010-04-27 16:04 b371b2c9  Makefile
+--------          -------  ---                            -------
+     160              100  38%                            1 file
+`},
+	{listInfo, "unix.zip", `Archive:  ../../testdata/unix.zip
+Zip file size: 567 bytes, number of entries: 5
+drwxr-xr-x  3.0 unx        0 b- stor 12-Jan-02 03:04 unix/
+-rw-r--r--  3.0 unx       16 t- stor 12-Jan-02 03:04 unix/readme.txt
+lrwxrwxrwx  3.0 unx       10 b- stor 12-Jan-02 03:04 unix/link.txt
+drwxr-xr-x  3.0 unx        0 b- stor 12-Jan-02 03:04 unix/bin/
+-rwxr-xr-x  3.0 unx       21 t- stor 12-Jan-02 03:04 unix/bin/hello.sh
+5 files, 47 bytes uncompressed, 47 bytes compressed:  0.0%
+`},
+	{listInfo, "descr.zip", `Archive:  ../../testdata/descr.zip
+Zip file size: 382 bytes, number of entries: 2
+-rw-rw-r--  3.0 unx       31 tX defN 14-Feb-04 16:28 stuf.txt
+-rw-rw-r--  3.0 unx        5 tX defN 14-Feb-04 16:28 mini.txt
+2 files, 36 bytes uncompressed, 40 bytes compressed:  -11.1%
+`},
+}
+
+// Purpose: the three text listings match what Info-ZIP prints
+func TestListings(t *testing.T) {
+	fmt.Printf("TestListings start\n")
+	// unzip shows extended timestamps in local time, the captures were made in UTC
+	defer func(loc *time.Location) { time.Local = loc }(time.Local)
+	time.Local = time.UTC
+	for _, l := range listings {
+		a, err := readArchive("../../testdata/"+l.archive, false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var b bytes.Buffer
+		l.list(&b, a)
+		if b.String() != l.want {
+			t.Errorf("%s: got\n%s\nexpected\n%s", l.archive, b.String(), l.want)
+		}
+	}
+	fmt.Printf("TestListings fini\n")
+}
+
+// Purpose: JSON output decodes and local header scanning finds the same entries
+func TestListJSON(t *testing.T) {
+	fmt.Printf("TestListJSON start\n")
+	central, err := readArchive("../../testdata/unix.zip", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	local, err := readArchive("../../testdata/unix.zip", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(local.Headers) != len(central.Headers) {
+		t.Errorf("local scan found %d entries, directory has %d", len(local.Headers), len(central.Headers))
+	}
+	var b bytes.Buffer
+	if err := listJSON(&b, []*archive{central}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var got []jsonArchive
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0].Files != 5 || got[0].TotalSize != 47 {
+		t.Fatalf("unexpected JSON %s", b.String())
+	}
+	link := got[0].Entries[2]
+	if link.Name != "unix/link.txt" || link.Mode != "lrwxrwxrwx" || link.Method != "Stored" || link.CRC32 == "" {
+		t.Errorf("unexpected entry %+v", link)
+	}
+	fmt.Printf("TestListJSON fini\n")
+}
+
+// Purpose: -i and -x narrow the listing, from either kind of header
+func TestSelector(t *testing.T) {
+	fmt.Printf("TestSelector start\n")
+	defer func() { selector = zipfile.Selector{} }()
+	var include, exclude globList
+	if err := include.Set("unix/**"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := exclude.Set("*.txt"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	selector = zipfile.Selector{Include: include, Exclude: exclude}
+	for _, local := range []bool{false, true} {
+		a, err := readArchive("../../testdata/unix.zip", local)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var names []string
+		for _, h := range a.Headers {
+			names = append(names, h.Name)
+		}
+		if got, want := fmt.Sprint(names), "[unix/ unix/bin/ unix/bin/hello.sh]"; got != want {
+			t.Errorf("local %v: got %s, expected %s", local, got, want)
+		}
+	}
+	fmt.Printf("TestSelector fini\n")
+}
@@ -0,0 +1,168 @@
+// zipmerge.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// zipmerge combines archives into one, copying entry data without
+// decompressing it.  -policy says what to do when two archives have an entry
+// with the same name: keep the first (the default), keep the last, fail, or
+// keep both with the later one renamed ("lib/util.js" becomes
+// "lib/util~1.js").  Directory entries are only written once.  The comment
+// of the first archive is kept.
+//
+//	zipmerge [-policy first|last|error|rename] [-v] merged.zip in.zip ...
+//
+// The merged archive is written to a temporary file beside merged.zip and
+// only renamed into place if all went well, so a failed merge leaves an
+// existing merged.zip as it was.  merged.zip can't also be an input.
+//
+// Exit status is 0 on success, 1 if -policy error found duplicates (they are
+// listed and nothing is written) and 2 for anything else.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hotei/go-zipfile"
+)
+
+var (
+	flagPolicy  = flag.String("policy", "first", "what to do with duplicate names: first, last, error or rename")
+	flagVerbose = flag.Bool("v", false, "log what happened to every entry")
+)
+
+var sameFileError = errors.New("output is also an input")
+
+var policies = map[string]zipfile.MergePolicy{
+	"first":  zipfile.MergeFirstWins,
+	"last":   zipfile.MergeLastWins,
+	"error":  zipfile.MergeError,
+	"rename": zipfile.MergeRename,
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("zipmerge: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zipmerge [-policy first|last|error|rename] [-v] merged.zip in.zip ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	policy, ok := policies[*flagPolicy]
+	if flag.NArg() < 2 || !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+	out := flag.Arg(0)
+	inputs := flag.Args()[1:]
+	var files []*os.File
+	var sources []*zipfile.ZipReader
+	var comment string
+	for ndx, name := range inputs {
+		f, rz, c, err := openSource(name)
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		if ndx == 0 {
+			comment = c
+		}
+		files = append(files, f)
+		sources = append(sources, rz)
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	report, err := mergeTo(out, files, sources, policy, comment)
+	for _, m := range report {
+		switch {
+		case err == zipfile.MergeConflictError && m.Skipped && !strings.HasSuffix(m.Orig, "/"):
+			log.Printf("duplicate %q in %s", m.Orig, inputs[m.Source])
+		case !*flagVerbose:
+		case m.Skipped:
+			log.Printf("skipped %s:%s", inputs[m.Source], m.Orig)
+		default:
+			log.Printf("added %s:%s as %s", inputs[m.Source], m.Orig, m.Name)
+		}
+	}
+	if err != nil {
+		if err == zipfile.MergeConflictError {
+			log.Print(err)
+			os.Exit(1)
+		}
+		log.Fatal(err)
+	}
+}
+
+// openSource opens one of the archives to merge and returns its comment
+func openSource(name string) (*os.File, *zipfile.ZipReader, string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	rz, err := zipfile.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, "", err
+	}
+	comment, err := rz.Comment()
+	if err != nil {
+		f.Close()
+		return nil, nil, "", err
+	}
+	return f, rz, comment, nil
+}
+
+// mergeTo writes the merged archive to a temporary file in out's directory
+// and renames it to out if the merge worked.  An out that is one of the
+// inputs is refused before anything is written.
+func mergeTo(out string, inputs []*os.File, sources []*zipfile.ZipReader, policy zipfile.MergePolicy, comment string) ([]zipfile.Merged, error) {
+	if ofi, err := os.Stat(out); err == nil {
+		for _, f := range inputs {
+			if fi, err := f.Stat(); err == nil && os.SameFile(ofi, fi) {
+				return nil, fmt.Errorf("%s: %v", out, sameFileError)
+			}
+		}
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(out), "zipmerge")
+	if err != nil {
+		return nil, err
+	}
+	report, err := merge(tmp, sources, policy, comment)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644) // TempFile makes it 0600
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), out)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return report, err
+}
+
+// merge writes the merged archive to w
+func merge(w io.Writer, sources []*zipfile.ZipReader, policy zipfile.MergePolicy, comment string) ([]zipfile.Merged, error) {
+	zw := zipfile.NewWriter(w)
+	zw.Comment = comment
+	report, err := zipfile.Merge(zw, sources, &zipfile.MergeOptions{Policy: policy})
+	if err != nil {
+		return report, err
+	}
+	return report, zw.Close()
+}
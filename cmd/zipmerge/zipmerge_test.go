@@ -0,0 +1,125 @@
+// zipmerge_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hotei/go-zipfile"
+)
+
+// Purpose: an archive merged with itself and a second one, with renaming,
+// has every file twice but each directory once
+func TestMerge(t *testing.T) {
+	fmt.Printf("TestMerge start\n")
+	var sources []*zipfile.ZipReader
+	var comment string
+	for _, name := range []string{"../../testdata/unix.zip", "../../testdata/unix.zip", "../../testdata/stuf.zip"} {
+		f, rz, c, err := openSource(name)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer f.Close()
+		if sources == nil {
+			comment = c
+		}
+		sources = append(sources, rz)
+	}
+	var buf bytes.Buffer
+	if _, err := merge(&buf, sources, zipfile.MergeRename, comment); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rz, err := zipfile.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, h := range filelist {
+		names[h.Name] = true
+	}
+	for _, name := range []string{"unix/", "unix/readme.txt", "unix/readme~1.txt", "unix/bin/hello~1.sh"} {
+		if !names[name] {
+			t.Errorf("%s missing from %v", name, names)
+		}
+	}
+	if names["unix/~1"] || names["unix/bin/~1"] {
+		t.Errorf("directory renamed: %v", names)
+	}
+
+	buf.Reset()
+	report, err := merge(&buf, sources, zipfile.MergeError, comment)
+	if err != zipfile.MergeConflictError {
+		t.Fatalf("expected MergeConflictError, got %v", err)
+	}
+	dups := 0
+	for _, m := range report {
+		if m.Skipped {
+			dups++
+		}
+	}
+	if dups != 5 {
+		t.Errorf("%d entries skipped, expected all 5 of the second unix.zip", dups)
+	}
+	fmt.Printf("TestMerge fini\n")
+}
+
+// Purpose: a failed merge leaves an existing output alone and an output that
+// is also an input is refused
+func TestMergeTo(t *testing.T) {
+	fmt.Printf("TestMergeTo start\n")
+	dir, err := ioutil.TempDir("", "zipmerge")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	orig, err := ioutil.ReadFile("../../testdata/unix.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out := filepath.Join(dir, "out.zip")
+	if err := ioutil.WriteFile(out, orig, 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var files []*os.File
+	var sources []*zipfile.ZipReader
+	for _, name := range []string{"../../testdata/unix.zip", out} {
+		f, rz, _, err := openSource(name)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer f.Close()
+		files = append(files, f)
+		sources = append(sources, rz)
+	}
+	if _, err := mergeTo(out, files, sources, zipfile.MergeFirstWins, ""); err == nil || !strings.Contains(err.Error(), sameFileError.Error()) {
+		t.Errorf("expected sameFileError, got %v", err)
+	}
+	if _, err := mergeTo(out, files[:1], sources, zipfile.MergeError, ""); err != zipfile.MergeConflictError {
+		t.Errorf("expected MergeConflictError, got %v", err)
+	}
+	if data, _ := ioutil.ReadFile(out); !bytes.Equal(data, orig) {
+		t.Errorf("failed merges changed the output")
+	}
+	if _, err := mergeTo(out, files[:1], sources, zipfile.MergeFirstWins, ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, "*")); len(names) != 1 {
+		t.Errorf("temporary files left behind: %v", names)
+	}
+	fmt.Printf("TestMergeTo fini\n")
+}
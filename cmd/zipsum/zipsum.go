@@ -0,0 +1,122 @@
+// zipsum.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// zipsum is sha256sum for the files inside an archive.  It prints a manifest
+// of checksums of every file's contents, in sha256sum format so that
+// "sha256sum -c" can check the extracted tree, or as JSON with -json, which
+// can carry several hashes.  With -c it checks an archive against a manifest
+// instead, saved from zipsum or sha256sum, and reports the files that don't
+// match.
+//
+//	zipsum [-a sha256[,md5,...]] [-json] archive.zip
+//	zipsum -c manifest archive.zip
+//
+// Exit status is 0 when all is well, 1 if -c found files that don't match and
+// 2 for anything else.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hotei/go-zipfile"
+)
+
+const (
+	exitOK       = 0
+	exitMismatch = 1
+	exitTrouble  = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run parses args and does the work, returning the exit status
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("zipsum", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: zipsum [-a sha256[,md5,...]] [-json] archive.zip\n")
+		fmt.Fprintf(stderr, "       zipsum -c manifest archive.zip\n")
+		fs.PrintDefaults()
+	}
+	hashes := fs.String("a", "sha256", "hashes to compute, comma separated: md5, sha1, sha256, sha512")
+	asJSON := fs.Bool("json", false, "write the manifest as JSON")
+	check := fs.String("c", "", "check the archive against this manifest")
+	if err := fs.Parse(args); err != nil {
+		return exitTrouble
+	}
+	list := strings.Split(*hashes, ",")
+	if fs.NArg() != 1 || (len(list) > 1 && !*asJSON && *check == "") {
+		fs.Usage()
+		return exitTrouble
+	}
+	name := fs.Arg(0)
+	f, err := os.Open(name)
+	if err != nil {
+		fmt.Fprintf(stderr, "zipsum: %v\n", err)
+		return exitTrouble
+	}
+	defer f.Close()
+	rz, err := zipfile.NewReader(f)
+	if err != nil {
+		fmt.Fprintf(stderr, "zipsum: %s: %v\n", name, err)
+		return exitTrouble
+	}
+	if *check != "" {
+		return verify(rz, *check, stdout, stderr)
+	}
+	m, err := rz.Manifest(list...)
+	if err != nil {
+		fmt.Fprintf(stderr, "zipsum: %s: %v\n", name, err)
+		return exitTrouble
+	}
+	if *asJSON {
+		err = m.WriteJSON(stdout)
+	} else {
+		err = m.WriteSums(stdout, list[0])
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "zipsum: %v\n", err)
+		return exitTrouble
+	}
+	return exitOK
+}
+
+// verify checks rz against the manifest saved in file, listing the problems
+// the way sha256sum -c lists failures
+func verify(rz *zipfile.ZipReader, file string, stdout, stderr io.Writer) int {
+	mf, err := os.Open(file)
+	if err != nil {
+		fmt.Fprintf(stderr, "zipsum: %v\n", err)
+		return exitTrouble
+	}
+	m, err := zipfile.ReadManifest(mf)
+	mf.Close()
+	if err != nil {
+		fmt.Fprintf(stderr, "zipsum: %s: %v\n", file, err)
+		return exitTrouble
+	}
+	problems, err := rz.VerifyManifest(m)
+	if err != nil {
+		fmt.Fprintf(stderr, "zipsum: %v\n", err)
+		return exitTrouble
+	}
+	for _, p := range problems {
+		fmt.Fprintf(stdout, "%s: FAILED %s\n", p.Name, p.Problem)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(stderr, "zipsum: WARNING: %d of %d files did not match\n", len(problems), len(m.Entries))
+		return exitMismatch
+	}
+	return exitOK
+}
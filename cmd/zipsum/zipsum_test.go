@@ -0,0 +1,62 @@
+// zipsum_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Purpose: a manifest zipsum writes checks out with -c, an edited one doesn't
+func TestZipsum(t *testing.T) {
+	fmt.Printf("TestZipsum start\n")
+	dir, err := ioutil.TempDir("", "zipsum")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	const archive = "../../testdata/unix.zip"
+	for _, args := range [][]string{{}, {"-json", "-a", "sha256,sha1"}} {
+		var stdout, stderr bytes.Buffer
+		if rc := run(append(args, archive), &stdout, &stderr); rc != exitOK {
+			t.Fatalf("%v: exit %d, %s", args, rc, stderr.String())
+		}
+		manifest := filepath.Join(dir, "manifest")
+		if err := ioutil.WriteFile(manifest, stdout.Bytes(), 0644); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		stdout.Reset()
+		if rc := run([]string{"-c", manifest, archive}, &stdout, &stderr); rc != exitOK || stdout.Len() != 0 {
+			t.Errorf("%v: -c exit %d, output %q", args, rc, stdout.String())
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	run([]string{archive}, &stdout, &stderr)
+	manifest := filepath.Join(dir, "edited")
+	edited := strings.Replace(stdout.String(), "a901", "0000", 1)
+	if err := ioutil.WriteFile(manifest, []byte(edited), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stdout.Reset()
+	if rc := run([]string{"-c", manifest, archive}, &stdout, &stderr); rc != exitMismatch {
+		t.Errorf("edited manifest: exit %d, expected %d", rc, exitMismatch)
+	}
+	if want := "unix/readme.txt: FAILED sha256\n"; stdout.String() != want {
+		t.Errorf("got %q, expected %q", stdout.String(), want)
+	}
+	if rc := run([]string{"-a", "sha256,md5", archive}, &stdout, &stderr); rc != exitTrouble {
+		t.Errorf("several hashes without -json: exit %d, expected %d", rc, exitTrouble)
+	}
+	fmt.Printf("TestZipsum fini\n")
+}
@@ -0,0 +1,191 @@
+// ziptar.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// ziptar converts a zip archive to a tar archive, or with -r a tar archive
+// to a zip archive.  A zip has to be a file, as it is read from the central
+// directory at its end, but a tar can come from standard input.  Output goes
+// to standard output unless -o names a file.  -z gzips the tar written, or
+// gunzips the tar read with -r.  A file named by -o is written under a
+// temporary name beside it and renamed into place at the end, it can't be
+// the archive being converted.
+//
+//	ziptar [-z] [-o out.tar] in.zip
+//	ziptar -r [-z] [-0] [-o out.zip] [in.tar]
+//
+// Exit status is 0 on success and 1 for anything else.
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hotei/go-zipfile"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+)
+
+var sameFileError = errors.New("output is the archive being converted")
+
+// ziptar is one run of the command
+type ziptar struct {
+	reverse, gz, store bool
+	out                string
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run parses args and does the work, returning the exit status
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	z := new(ziptar)
+	fs := flag.NewFlagSet("ziptar", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: ziptar [-z] [-o out.tar] in.zip\n")
+		fmt.Fprintf(stderr, "       ziptar -r [-z] [-0] [-o out.zip] [in.tar]\n")
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&z.reverse, "r", false, "convert tar to zip")
+	fs.BoolVar(&z.gz, "z", false, "the tar is gzipped")
+	fs.BoolVar(&z.store, "0", false, "store zip entries without compressing them")
+	fs.StringVar(&z.out, "o", "", "write to this file instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if (!z.reverse && fs.NArg() != 1) || fs.NArg() > 1 || (z.store && !z.reverse) {
+		fs.Usage()
+		return exitError
+	}
+	convert := func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		var err error
+		if z.reverse {
+			err = z.toZip(bw, stdin, fs.Arg(0))
+		} else {
+			err = z.toTar(bw, fs.Arg(0))
+		}
+		if err == nil {
+			err = bw.Flush()
+		}
+		return err
+	}
+	var err error
+	if z.out != "" {
+		err = writeTo(z.out, fs.Arg(0), convert)
+	} else {
+		err = convert(stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "ziptar: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// writeTo has write fill a temporary file in out's directory and renames it
+// to out if that worked, so a failed run leaves whatever out was alone.  An
+// out that is the input itself is refused before anything is written.
+func writeTo(out, in string, write func(io.Writer) error) error {
+	if in != "" {
+		ofi, oerr := os.Stat(out)
+		ifi, ierr := os.Stat(in)
+		if oerr == nil && ierr == nil && os.SameFile(ofi, ifi) {
+			return fmt.Errorf("%s: %v", out, sameFileError)
+		}
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(out), "ziptar")
+	if err != nil {
+		return err
+	}
+	err = write(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644) // TempFile makes it 0600
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), out)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// toTar writes the zip archive called name to w as a tar
+func (z *ziptar) toTar(w io.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rz, err := zipfile.NewReader(f)
+	if err != nil {
+		return err
+	}
+	var gw *gzip.Writer
+	if z.gz {
+		gw = gzip.NewWriter(w)
+		w = gw
+	}
+	tw := tar.NewWriter(w)
+	if err := zipfile.ZipToTar(tw, rz); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gw != nil {
+		return gw.Close()
+	}
+	return nil
+}
+
+// toZip writes the tar archive called name, or stdin if there's no name, to
+// w as a zip
+func (z *ziptar) toZip(w io.Writer, stdin io.Reader, name string) error {
+	r := stdin
+	if name != "" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	if z.gz {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	}
+	method := uint16(zipfile.ZIP_DEFLATED)
+	if z.store {
+		method = zipfile.ZIP_STORED
+	}
+	zw := zipfile.NewWriter(w)
+	if err := zipfile.TarToZip(zw, tar.NewReader(r), method); err != nil {
+		return err
+	}
+	return zw.Close()
+}
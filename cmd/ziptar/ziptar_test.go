@@ -0,0 +1,101 @@
+// ziptar_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hotei/go-zipfile"
+)
+
+// Purpose: zip to tar.gz through the command and back again, from stdin
+func TestZiptar(t *testing.T) {
+	fmt.Printf("TestZiptar start\n")
+	var tgz, stderr bytes.Buffer
+	if rc := run([]string{"-z", "../../testdata/unix.zip"}, nil, &tgz, &stderr); rc != exitOK {
+		t.Fatalf("to tar: exit %d, %s", rc, stderr.String())
+	}
+	var zipped bytes.Buffer
+	if rc := run([]string{"-r", "-z", "-0"}, &tgz, &zipped, &stderr); rc != exitOK {
+		t.Fatalf("to zip: exit %d, %s", rc, stderr.String())
+	}
+	rz, err := zipfile.NewReader(bytes.NewReader(zipped.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var names []string
+	for _, h := range filelist {
+		names = append(names, h.Name)
+		if h.Compress != zipfile.ZIP_STORED {
+			t.Errorf("%s compressed with -0", h.Name)
+		}
+	}
+	want := "[unix/ unix/readme.txt unix/link.txt unix/bin/ unix/bin/hello.sh]"
+	if fmt.Sprint(names) != want {
+		t.Errorf("got %v, expected %s", names, want)
+	}
+	if rc := run([]string{"-r", "-z"}, bytes.NewReader([]byte("not gzip")), &zipped, &stderr); rc != exitError {
+		t.Errorf("bad input: exit %d, expected %d", rc, exitError)
+	}
+	fmt.Printf("TestZiptar fini\n")
+}
+
+// Purpose: -o can't overwrite the archive being converted, a failed run
+// leaves the old output alone and a good one leaves only the output
+func TestZiptarOut(t *testing.T) {
+	fmt.Printf("TestZiptarOut start\n")
+	dir, err := ioutil.TempDir("", "ziptar")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	orig, err := ioutil.ReadFile("../../testdata/unix.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	in := filepath.Join(dir, "in.zip")
+	if err = ioutil.WriteFile(in, orig, 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var stderr bytes.Buffer
+	if rc := run([]string{"-o", in, in}, nil, nil, &stderr); rc != exitError ||
+		!strings.Contains(stderr.String(), sameFileError.Error()) {
+		t.Errorf("same file: exit %d, %s", rc, stderr.String())
+	}
+	if data, _ := ioutil.ReadFile(in); !bytes.Equal(data, orig) {
+		t.Errorf("refused run changed the input")
+	}
+	out := filepath.Join(dir, "out.zip")
+	if err = ioutil.WriteFile(out, []byte("old"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rc := run([]string{"-r", "-o", out}, bytes.NewReader([]byte("not a tar")), nil, &stderr); rc != exitError {
+		t.Errorf("bad input: exit %d, expected %d", rc, exitError)
+	}
+	if data, _ := ioutil.ReadFile(out); string(data) != "old" {
+		t.Errorf("failed run replaced the output with %q", data)
+	}
+	tarred := filepath.Join(dir, "out.tar")
+	if rc := run([]string{"-o", tarred, in}, nil, nil, &stderr); rc != exitOK {
+		t.Fatalf("to tar: exit %d, %s", rc, stderr.String())
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, "*")); len(names) != 3 {
+		t.Errorf("expected in.zip, out.zip and out.tar, got %v", names)
+	}
+	fmt.Printf("TestZiptarOut fini\n")
+}
@@ -0,0 +1,86 @@
+// compress.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// The package only knows stored and deflated data itself.  Other methods
+// (bzip2, LZMA, zstd ...) can be plugged in by registering a compressor for
+// the Writer and a decompressor for the readers under the method number
+// APPNOTE.TXT gives them.
+
+package zipfile
+
+import (
+	"compress/flate"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Compressor returns a writer that compresses what it is given into w.
+// Close must flush everything to w.
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+// Decompressor returns a reader that expands the compressed data in r
+type Decompressor func(r io.Reader) io.ReadCloser
+
+var (
+	methodLock    sync.RWMutex
+	compressors   = map[uint16]Compressor{ZIP_DEFLATED: newFlateWriter}
+	decompressors = map[uint16]Decompressor{ZIP_DEFLATED: flate.NewReader}
+)
+
+// RegisterCompressor makes method available to Writer.Create, replacing any
+// compressor already registered for it, for every Writer.  To change the
+// compression level of one archive use Writer.RegisterCompressor instead.
+func RegisterCompressor(method uint16, c Compressor) {
+	methodLock.Lock()
+	compressors[method] = c
+	methodLock.Unlock()
+}
+
+// RegisterDecompressor makes entries compressed with method readable
+func RegisterDecompressor(method uint16, d Decompressor) {
+	methodLock.Lock()
+	decompressors[method] = d
+	methodLock.Unlock()
+}
+
+// Compressors lists the methods the Writer can use, ZIP_STORED included
+func Compressors() []uint16 {
+	methodLock.RLock()
+	defer methodLock.RUnlock()
+	methods := []uint16{ZIP_STORED}
+	for m := range compressors {
+		if m != ZIP_STORED {
+			methods = append(methods, m)
+		}
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i] < methods[j] })
+	return methods
+}
+
+// compressor returns the compressor for method, nil if there is none
+func compressor(method uint16) Compressor {
+	methodLock.RLock()
+	defer methodLock.RUnlock()
+	return compressors[method]
+}
+
+// decompressor returns the decompressor for method, nil if there is none
+func decompressor(method uint16) Decompressor {
+	methodLock.RLock()
+	defer methodLock.RUnlock()
+	return decompressors[method]
+}
+
+// knownMethod is true if entries compressed with method can be read
+func knownMethod(method uint16) bool {
+	return method == ZIP_STORED || decompressor(method) != nil
+}
+
+func newFlateWriter(w io.Writer) (io.WriteCloser, error) {
+	return flate.NewWriter(w, flate.DefaultCompression)
+}
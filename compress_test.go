@@ -0,0 +1,155 @@
+// compress_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+const methodXor = 200 // not a real method, bytes xor'ed with 0x5a
+
+type xorWriter struct {
+	w io.Writer
+}
+
+func (x xorWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	for ndx, c := range p {
+		b[ndx] = c ^ 0x5a
+	}
+	return x.w.Write(b)
+}
+
+func (x xorWriter) Close() error { return nil }
+
+type xorReader struct {
+	r io.Reader
+}
+
+func (x xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for ndx := range p[:n] {
+		p[ndx] ^= 0x5a
+	}
+	return n, err
+}
+
+func (x xorReader) Close() error { return nil }
+
+// Purpose: a registered method can be written and read back, and is refused
+// by both Create and Open once it is gone again
+func TestRegisterMethod(t *testing.T) {
+	fmt.Printf("TestRegisterMethod start\n")
+	RegisterCompressor(methodXor, func(w io.Writer) (io.WriteCloser, error) { return xorWriter{w}, nil })
+	RegisterDecompressor(methodXor, func(r io.Reader) io.ReadCloser { return xorReader{r} })
+	defer func() {
+		methodLock.Lock()
+		delete(compressors, methodXor)
+		delete(decompressors, methodXor)
+		methodLock.Unlock()
+	}()
+	methods := Compressors()
+	if len(methods) != 3 || methods[0] != ZIP_STORED || methods[1] != ZIP_DEFLATED || methods[2] != methodXor {
+		t.Errorf("Compressors() = %v", methods)
+	}
+
+	var buf bytes.Buffer
+	zw := NewWriter(&buf)
+	fw, err := zw.Create(&Header{Name: "xor.txt", Compress: methodXor})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	io.WriteString(fw, "hidden in plain sight")
+	if err = zw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("plain sight")) {
+		t.Errorf("data was stored, not compressed")
+	}
+
+	rz, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rdr, err := filelist[0].Open()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := ioutil.ReadAll(rdr)
+	if err != nil || string(data) != "hidden in plain sight" {
+		t.Errorf("read back %q, %v", data, err)
+	}
+
+	defer func(p bool) { Paranoid = p }(Paranoid)
+	Paranoid = false
+	methodLock.Lock()
+	delete(compressors, methodXor)
+	delete(decompressors, methodXor)
+	methodLock.Unlock()
+	if _, err = filelist[0].Open(); err != InvalidCompError {
+		t.Errorf("no decompressor: got %v, expected InvalidCompError", err)
+	}
+	if _, err = NewWriter(ioutil.Discard).Create(&Header{Name: "x", Compress: methodXor}); err != InvalidCompError {
+		t.Errorf("unregistered method: got %v, expected InvalidCompError", err)
+	}
+	fmt.Printf("TestRegisterMethod fini\n")
+}
+
+// Purpose: a compressor registered on one Writer is used by it and no other
+func TestWriterCompressor(t *testing.T) {
+	fmt.Printf("TestWriterCompressor start\n")
+	data := bytes.Repeat([]byte("the same line over and over\n"), 100)
+	write := func(zw *Writer, buf *bytes.Buffer) *Header {
+		h := &Header{Name: "a.txt", Compress: ZIP_DEFLATED}
+		fw, err := zw.Create(h)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		fw.Write(data)
+		if err = zw.Close(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return h
+	}
+	var plain, flat bytes.Buffer
+	zw := NewWriter(&flat)
+	zw.RegisterCompressor(ZIP_DEFLATED, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, flate.NoCompression)
+	})
+	if h := write(zw, &flat); h.SizeCompr <= h.Size {
+		t.Errorf("NoCompression gave %d bytes from %d", h.SizeCompr, h.Size)
+	}
+	if h := write(NewWriter(&plain), &plain); h.SizeCompr >= h.Size/10 {
+		t.Errorf("another Writer used NoCompression too, %d bytes from %d", h.SizeCompr, h.Size)
+	}
+	rz, err := NewReader(bytes.NewReader(flat.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rdr, err := filelist[0].Open()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, err := ioutil.ReadAll(rdr); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("read back %d bytes, %v", len(got), err)
+	}
+	fmt.Printf("TestWriterCompressor fini\n")
+}
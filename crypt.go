@@ -0,0 +1,92 @@
+// crypt.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Traditional PKWARE encryption, "ZipCrypto", section 6.1 of APPNOTE.TXT.
+// It is weak and only decryption is offered, so that archives made with
+// "zip -e" or "zip -P" can be read.  AES encrypted entries (method 99) are
+// not supported.
+
+package zipfile
+
+import (
+	"errors"
+	"hash/crc32"
+)
+
+const (
+	flagEncrypted   = 0x0001
+	cryptHeaderSize = 12 // random bytes plus a check byte, in front of the data
+)
+
+var (
+	PasswordError    = errors.New("entry is encrypted and no password was given")
+	BadPasswordError = errors.New("incorrect password")
+)
+
+// SetPassword gives the password for encrypted entries.  Open() on an
+// encrypted entry returns PasswordError until this is called.
+func (r *ZipReader) SetPassword(pw string) {
+	r.password = []byte(pw)
+}
+
+// Encrypted is true if the entry's data is encrypted
+func (h *Header) Encrypted() bool {
+	return h.Flags&flagEncrypted != 0
+}
+
+// decrypt strips the encryption header from the compressed data and
+// decrypts the rest
+func (h *Header) decrypt(data []byte) ([]byte, error) {
+	if h.zr == nil || h.zr.password == nil {
+		return nil, PasswordError
+	}
+	if len(data) < cryptHeaderSize {
+		return nil, ShortReadError
+	}
+	k := newCryptKeys(h.zr.password)
+	plain := make([]byte, len(data))
+	for ndx, c := range data {
+		plain[ndx] = k.decryptByte(c)
+	}
+	// the last header byte repeats the top of the CRC, or of the DOS time
+	// when the CRC wasn't known in time (data descriptor)
+	check := byte(h.StoredCrc32 >> 24)
+	if h.Flags&flagDataDesc != 0 {
+		check = byte(h.dosTime >> 8)
+	}
+	if plain[cryptHeaderSize-1] != check {
+		return nil, BadPasswordError
+	}
+	return plain[cryptHeaderSize:], nil
+}
+
+type cryptKeys [3]uint32
+
+func newCryptKeys(pw []byte) *cryptKeys {
+	k := &cryptKeys{0x12345678, 0x23456789, 0x34567890}
+	for _, c := range pw {
+		k.update(c)
+	}
+	return k
+}
+
+func crcUpdate(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ crc>>8
+}
+
+func (k *cryptKeys) update(b byte) {
+	k[0] = crcUpdate(k[0], b)
+	k[1] = (k[1]+k[0]&0xff)*134775813 + 1
+	k[2] = crcUpdate(k[2], byte(k[1]>>24))
+}
+
+func (k *cryptKeys) decryptByte(c byte) byte {
+	t := uint16(k[2] | 2)
+	p := c ^ byte(t*(t^1)>>8)
+	k.update(p)
+	return p
+}
@@ -0,0 +1,73 @@
+// crypt_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Purpose: read entries made with "zip -P swordfish", with the right
+// password, the wrong one and none
+func TestDecrypt(t *testing.T) {
+	fmt.Printf("TestDecrypt start\n")
+	f, err := os.Open("testdata/crypt.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"secret.txt": "secret contents, not for everyone\n",
+		"big.txt":    strings.Repeat("hello hello hello hello hello ", 20) + "\n",
+		"plain.txt":  "plain\n",
+	}
+	read := func(h *Header) (string, error) {
+		rdr, err := h.Open()
+		if err != nil {
+			return "", err
+		}
+		data, err := ioutil.ReadAll(rdr)
+		return string(data), err
+	}
+	for _, h := range filelist {
+		if h.Encrypted() != (h.Name != "plain.txt") {
+			t.Errorf("%s: Encrypted() is %v", h.Name, h.Encrypted())
+		}
+		if _, err := read(h); h.Encrypted() && err != PasswordError {
+			t.Errorf("%s: expected PasswordError, got %v", h.Name, err)
+		}
+	}
+	rz.SetPassword("sw0rdfish")
+	for _, h := range filelist {
+		if _, err := read(h); h.Encrypted() && err != BadPasswordError {
+			t.Errorf("%s: expected BadPasswordError, got %v", h.Name, err)
+		}
+	}
+	rz.SetPassword("swordfish")
+	for _, h := range filelist {
+		got, err := read(h)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", h.Name, err)
+		}
+		if got != want[h.Name] {
+			t.Errorf("%s: got %q, expected %q", h.Name, got, want[h.Name])
+		}
+	}
+	fmt.Printf("TestDecrypt fini\n")
+}
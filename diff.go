@@ -0,0 +1,173 @@
+// diff.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Comparing two archives entry by entry, for finding out why a build
+// artifact changed.  The central directories are enough for most of it,
+// contents are only read when asked for.
+
+package zipfile
+
+import (
+	"bytes"
+	"io/ioutil"
+)
+
+// DiffKind says how an entry differs between two archives
+type DiffKind int
+
+const (
+	DiffAdded   DiffKind = iota // only in the new archive
+	DiffRemoved                 // only in the old archive
+	DiffChanged                 // in both, but different
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// what EntryDiff.Changes can list
+const (
+	ChangedSize    = "size"
+	ChangedCrc     = "crc"
+	ChangedMtime   = "mtime"
+	ChangedMethod  = "method"
+	ChangedMode    = "mode"
+	ChangedComment = "comment"
+	ChangedContent = "content" // only with DiffOptions.Content
+)
+
+// EntryDiff is one entry that isn't the same in both archives
+type EntryDiff struct {
+	Kind     DiffKind
+	Name     string
+	Old, New *Header  // Old is nil when added, New when removed
+	Changes  []string // what differs when Kind is DiffChanged, Changed* values
+}
+
+// DiffOptions configure Diff, nil compares everything but contents
+type DiffOptions struct {
+	IgnoreMtime  bool // rebuilt artifacts differ in time stamps and nothing else
+	IgnoreMethod bool // recompressed is not changed
+	// Content reads both copies of every entry that is in both archives
+	// and compares the data, instead of trusting size and CRC.
+	Content bool
+}
+
+// Diff compares two archives by name.  The result has the entries of from in
+// order, removed or changed, followed by those only in to.  Names that
+// appear twice in one archive are compared by their first entry.
+func Diff(from, to *ZipReader, opts *DiffOptions) ([]EntryDiff, error) {
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+	oldList, err := from.CentralHeaders()
+	if err != nil {
+		return nil, err
+	}
+	newList, err := to.CentralHeaders()
+	if err != nil {
+		return nil, err
+	}
+	newByName := make(map[string]*Header, len(newList))
+	for _, h := range newList {
+		if newByName[h.Name] == nil {
+			newByName[h.Name] = h
+		}
+	}
+	var diffs []EntryDiff
+	seen := make(map[string]bool, len(oldList))
+	for _, oh := range oldList {
+		if seen[oh.Name] {
+			continue
+		}
+		seen[oh.Name] = true
+		nh := newByName[oh.Name]
+		if nh == nil {
+			diffs = append(diffs, EntryDiff{Kind: DiffRemoved, Name: oh.Name, Old: oh})
+			continue
+		}
+		changes, err := compareEntries(oh, nh, opts)
+		if err != nil {
+			return diffs, err
+		}
+		if len(changes) > 0 {
+			diffs = append(diffs, EntryDiff{Kind: DiffChanged, Name: oh.Name, Old: oh, New: nh, Changes: changes})
+		}
+	}
+	for _, nh := range newList {
+		if !seen[nh.Name] {
+			seen[nh.Name] = true
+			diffs = append(diffs, EntryDiff{Kind: DiffAdded, Name: nh.Name, New: nh})
+		}
+	}
+	return diffs, nil
+}
+
+// compareEntries lists what differs between two entries with the same name
+func compareEntries(a, b *Header, opts *DiffOptions) ([]string, error) {
+	var changes []string
+	if a.Size != b.Size {
+		changes = append(changes, ChangedSize)
+	}
+	if a.StoredCrc32 != b.StoredCrc32 {
+		changes = append(changes, ChangedCrc)
+	}
+	if !opts.IgnoreMtime && !a.ModTime().Equal(b.ModTime()) {
+		changes = append(changes, ChangedMtime)
+	}
+	if !opts.IgnoreMethod && a.Compress != b.Compress {
+		changes = append(changes, ChangedMethod)
+	}
+	if a.Mode() != b.Mode() {
+		changes = append(changes, ChangedMode)
+	}
+	if a.Comment != b.Comment {
+		changes = append(changes, ChangedComment)
+	}
+	if opts.Content {
+		same, err := sameContents(a, b)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			changes = append(changes, ChangedContent)
+		}
+	}
+	return changes, nil
+}
+
+// sameContents reads both entries and compares them
+func sameContents(a, b *Header) (bool, error) {
+	if a.Size != b.Size {
+		return false, nil
+	}
+	ra, err := a.Open()
+	if err != nil {
+		return false, err
+	}
+	da, err := ioutil.ReadAll(ra)
+	if err != nil {
+		return false, err
+	}
+	rb, err := b.Open()
+	if err != nil {
+		return false, err
+	}
+	db, err := ioutil.ReadAll(rb)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(da, db), nil
+}
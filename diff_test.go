@@ -0,0 +1,107 @@
+// diff_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type diffEntry struct {
+	name  string
+	mode  os.FileMode
+	mtime int // seconds past 2012-03-04 05:06:00
+	data  string
+}
+
+func diffArchive(t *testing.T, entries []diffEntry) *ZipReader {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, e := range entries {
+		h := &Header{Name: e.name, Compress: ZIP_DEFLATED,
+			Mtime: time.Date(2012, 3, 4, 5, 6, e.mtime, 0, time.UTC)}
+		h.SetMode(e.mode)
+		fw, err := w.Create(h)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		io.WriteString(fw, e.data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rz, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return rz
+}
+
+// Purpose: added, removed and changed entries are found and the changes named
+func TestDiff(t *testing.T) {
+	fmt.Printf("TestDiff start\n")
+	from := diffArchive(t, []diffEntry{
+		{"same.txt", 0644, 0, "same"},
+		{"content.txt", 0644, 0, "old contents"},
+		{"gone.txt", 0644, 0, "gone"},
+		{"run.sh", 0644, 0, "#!/bin/sh"},
+		{"touched.txt", 0644, 0, "touched"},
+	})
+	to := diffArchive(t, []diffEntry{
+		{"new.txt", 0644, 0, "new"},
+		{"touched.txt", 0644, 10, "touched"},
+		{"run.sh", 0755, 0, "#!/bin/sh"},
+		{"content.txt", 0644, 0, "new contents"},
+		{"same.txt", 0644, 0, "same"},
+	})
+	type result struct {
+		kind    DiffKind
+		name    string
+		changes []string
+	}
+	tests := []struct {
+		opts *DiffOptions
+		want []result
+	}{
+		{nil, []result{
+			{DiffChanged, "content.txt", []string{ChangedCrc}},
+			{DiffRemoved, "gone.txt", nil},
+			{DiffChanged, "run.sh", []string{ChangedMode}},
+			{DiffChanged, "touched.txt", []string{ChangedMtime}},
+			{DiffAdded, "new.txt", nil},
+		}},
+		{&DiffOptions{IgnoreMtime: true, Content: true}, []result{
+			{DiffChanged, "content.txt", []string{ChangedCrc, ChangedContent}},
+			{DiffRemoved, "gone.txt", nil},
+			{DiffChanged, "run.sh", []string{ChangedMode}},
+			{DiffAdded, "new.txt", nil},
+		}},
+	}
+	for ndx, tt := range tests {
+		diffs, err := Diff(from, to, tt.opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var got []result
+		for _, d := range diffs {
+			got = append(got, result{d.Kind, d.Name, d.Changes})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test %d: got %v, expected %v", ndx, got, tt.want)
+		}
+	}
+	if diffs, err := Diff(from, from, &DiffOptions{Content: true}); err != nil || len(diffs) != 0 {
+		t.Errorf("archive differs from itself: %v, %v", diffs, err)
+	}
+	fmt.Printf("TestDiff fini\n")
+}
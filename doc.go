@@ -26,11 +26,14 @@ you wouldn't have to handle every diskette in a 20 volume set to find which disk
 held a specific file.  The restore program would read the last diskette and then
 prompt you to insert the next appropriate diskette.
 
-In our case it doesn't matter, because this version doesn't know or care
-if it's multi-volume. This version of the zip library does NOT
+Once the pieces are copied off the diskettes (name.z01, name.z02 ... name.zip)
+OpenSplit() or OpenVolumes() joins them back into one stream and NewVolumeReader()
+makes a reader that follows the disk numbers in the central directory.
+Headers() and Next() do NOT
 look at the central header areas at
-the end of the zip archive.  Instead it builds headers on the fly by reading the
-actual archived data. Additionally reading the actual data may be useful to validate
+the end of the zip archive.  Instead they build headers on the fly by reading the
+actual archived data.  CentralHeaders() reads the central directory when you need
+the unix permissions, file types and symlinks that only live there. Additionally reading the actual data may be useful to validate
 the readability of older removeable media like 5.25 inch diskettes and early CDs.
 
 The initial approach was to convert python's zipfile.py into go.  Since then the
@@ -39,6 +42,61 @@ based on PKWARE's APPNOTE.TXT.  APPNOTE.TXT describes the contents of zip files
 from the perspective of the company who designed the zip protocol.  The resulting zip.go
 library is ready for beta-testing and passes the initial test suite.
 
+Self-extracting archives and zips with a script stuck on the front work too,
+Prefix() reports how many bytes come before the archive proper.
+
+Carve() digs archives out of disk images and other files that merely contain
+zip data, reporting whole archives, truncated ones and lone entries with a
+confidence score for each.
+
+Entries encrypted with traditional PKWARE encryption can be read once the
+password is given with SetPassword().
+
+Stored and deflated data are handled by the package itself.  Other methods
+can be added with RegisterCompressor() and RegisterDecompressor().
+
+A Writer with Deterministic set writes reproducible archives, the same
+entries give the same bytes whatever order they came in, with time stamps
+taken from SOURCE_DATE_EPOCH.
+
+OpenAppend() adds entries to an existing archive in place, writing them
+where the old central directory was without touching the entries already
+there.
+
+ZipReader.Edit() writes a copy of an archive with entries deleted, renamed,
+replaced or re-dated.  Entries whose contents don't change are copied as they
+are stored, nothing is decompressed and compressed again.  Header.OpenRaw()
+and Writer.CreateRaw() do the same for any program that copies entries from
+one archive to another.
+
+Merge() combines archives, copying entry data raw, with a choice of what to
+do about duplicate names.  Diff() compares two archives entry by entry and
+says which entries were added, removed or changed, and how.  Manifest()
+checksums the contents of every file, in sha256sum format or JSON, and
+VerifyManifest() checks an archive against a saved manifest.  ZipToTar() and
+TarToZip() convert between zip and archive/tar, keeping names, modes, times
+and symlinks.  Walk() visits every entry of an archive and of the archives
+inside it, jars in wars in zips, naming them outer.zip!/lib/inner.jar!/a.txt.
+Stored inner archives are read in place, others are expanded in memory.
+
+A Selector picks entries by glob (with ** for any depth), regular expression,
+size and date, with include and exclude lists.  Select() lists what it picks
+and its Match method is the Filter for Extract() and Merge().  Grep()
+searches the contents of entries for a regular expression, several entries
+at a time, nested archives included if asked.
+
+Command line tools built on the library live under cmd/.  zipls lists archives
+the way unzip -l, unzip -v or zipinfo would, or as JSON, all entries or
+those matching -i and -x globs.  zipcheck reads every
+entry of every archive under a directory and reports CRC errors, truncation,
+bad signatures and impossible dates.  zipfix salvages the good entries of a
+damaged archive into a new one, like zip -FF.  unzip extracts, lists, tests
+or pipes entries, and decrypts those made with zip -e given the password.
+zip archives files and directory trees, optionally reproducibly.  zipmerge
+combines archives.  zipdiff compares two, with unified diffs of changed text.
+zipsum writes and checks manifests.  ziptar converts zip to tar and back.
+zipgrep searches inside entries.
+
 So far all testing has been on zip files smaller than 20 megabytes.
 
 REFERENCES:
@@ -47,7 +105,9 @@ REFERENCES:
 
 LIMITATIONS:
 
-Probably most significant is that this is a read-only library at present.
+Writing is simple minded.  NewWriter() and NewSplitWriter() can make stored and
+deflated entries, but each entry is compressed in memory before it is written
+and there is no zip64 support, so nothing over 4GB.
 
 At present there is a limitation of 2GB on expanded
 files if you set paranoid mode - ie if you want CRC32 checking done after
@@ -78,6 +138,12 @@ run the IEEEcrc32().  This obviously depends on the size of the files you're
 working with as well as your system's capacity. This behavior is on the TODO list
 for improvement to reduce memory footprint.
 
+If you are reading archives from people you don't trust, set DefaultLimits (or
+call SetLimits on the reader) so a small archive can't expand into something
+that fills memory.  Limits are checked against what the decompressor actually
+produces, not just what the header claims, and a *LimitError is returned
+whether or not Paranoid is set.
+
 There may be an opportunity to do some additional checking
 in paranoid mode by comparing the actual headers with the ones stored in the
 Central Directorys.  That's also on the "TODO" list.
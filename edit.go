@@ -0,0 +1,159 @@
+// edit.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Editing an archive means writing a new one.  Entries that keep their
+// contents are copied as stored, compressed bytes and all, so deleting or
+// renaming an entry in a big archive doesn't inflate and deflate everything
+// else again, and encrypted entries can be moved around without the password.
+
+package zipfile
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// EditOp says what an Edit does
+type EditOp int
+
+const (
+	EditDelete  EditOp = iota // leave the entry out
+	EditRename                // call it NewName
+	EditReplace               // new contents from Data
+	EditMtime                 // set the modification time to Mtime
+	EditComment               // set the entry comment to Comment
+)
+
+var EditNameError = errors.New("edit names an entry the archive doesn't have")
+
+// Edit is one change for ZipReader.Edit
+type Edit struct {
+	Op      EditOp
+	Name    string    // entry to change, as it is named in the original archive
+	NewName string    // for EditRename
+	Data    io.Reader // for EditReplace
+	Mtime   time.Time // for EditMtime
+	Comment string    // for EditComment
+}
+
+// Edit writes a copy of the archive to w with edits applied.  An entry can
+// have several edits, a rename and a new comment say, and they all refer to
+// it by its original name.  Entries keep their order, the archive keeps its
+// comment.  Replaced contents are compressed the way the old contents were,
+// or deflated if that method can't be written, everything else is copied
+// without decompressing it.  Renaming a directory doesn't rename what is in
+// it, each entry needs its own edit.
+//
+// Edits naming entries the archive doesn't have fail with EditNameError,
+// renames that would give two entries one name with DuplicateNameError.
+// Changing the time of an encrypted entry that has a data descriptor makes
+// the password check fail, the check byte comes from the old time.
+func (r *ZipReader) Edit(w io.Writer, edits []Edit) error {
+	filelist, err := r.CentralHeaders()
+	if err != nil {
+		return err
+	}
+	e, err := findEndCentDir(r.reader)
+	if err != nil {
+		return err
+	}
+	have := make(map[string]bool, len(filelist))
+	for _, h := range filelist {
+		have[h.Name] = true
+	}
+	byName := make(map[string][]Edit)
+	for _, ed := range edits {
+		if !have[ed.Name] {
+			return EditNameError
+		}
+		byName[ed.Name] = append(byName[ed.Name], ed)
+	}
+
+	zw := NewWriter(w)
+	zw.Comment = e.comment
+	zw.names = make(map[string]bool, len(filelist)) // catch renames onto other entries
+	for _, h := range filelist {
+		nh := rawHeader(h)
+		var data io.Reader
+		deleted := false
+		for _, ed := range byName[h.Name] {
+			switch ed.Op {
+			case EditDelete:
+				deleted = true
+			case EditRename:
+				nh.Name = ed.NewName
+			case EditReplace:
+				data = ed.Data
+			case EditMtime:
+				nh.Mtime = ed.Mtime
+				nh.Extra = withoutExtra(nh.Extra, extTimestampID) // or ModTime would still say the old time
+			case EditComment:
+				nh.Comment = ed.Comment
+			}
+		}
+		if deleted {
+			continue
+		}
+		if data != nil {
+			if nh.Compress != ZIP_STORED && compressor(nh.Compress) == nil {
+				nh.Compress = ZIP_DEFLATED
+			}
+			fw, err := zw.Create(nh)
+			if err != nil {
+				return err
+			}
+			if _, err = io.Copy(fw, data); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = copyRaw(zw, h, nh); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// rawHeader is a copy of h for CreateRaw, with what the data and the
+// directory need and nothing that belongs to the old archive.  Extra goes
+// along, the Writer drops its zip64 record.
+func rawHeader(h *Header) *Header {
+	return &Header{
+		Name:          h.Name,
+		Mtime:         h.Mtime,
+		Compress:      h.Compress,
+		StoredCrc32:   h.StoredCrc32,
+		Size:          h.Size,
+		VersionMadeBy: h.VersionMadeBy,
+		ExternalAttrs: h.ExternalAttrs,
+		InternalAttrs: h.InternalAttrs,
+		Flags:         h.Flags,
+		Extra:         h.Extra,
+		Comment:       h.Comment,
+	}
+}
+
+// copyRaw writes h's stored data to zw as entry nh
+func copyRaw(zw *Writer, h, nh *Header) error {
+	src, err := h.OpenRaw()
+	if err != nil {
+		return err
+	}
+	fw, err := zw.CreateRaw(nh)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(fw, src)
+	if err != nil {
+		return err
+	}
+	if n != h.SizeCompr {
+		return ShortReadError
+	}
+	return nil
+}
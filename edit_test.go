@@ -0,0 +1,197 @@
+// edit_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// centralByName reads the directory of an archive in memory
+func centralByName(t *testing.T, archive []byte) (*ZipReader, map[string]*Header) {
+	rz, err := NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	hdrs := make(map[string]*Header)
+	for _, h := range filelist {
+		hdrs[h.Name] = h
+	}
+	return rz, hdrs
+}
+
+func rawBytes(t *testing.T, h *Header) []byte {
+	src, err := h.OpenRaw()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := ioutil.ReadAll(src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return data
+}
+
+// Purpose: delete, rename, replace, mtime and comment edits, with untouched
+// entries copied byte for byte, encrypted ones included
+func TestEdit(t *testing.T) {
+	fmt.Printf("TestEdit start\n")
+	orig, err := ioutil.ReadFile("testdata/crypt.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rz, before := centralByName(t, orig)
+	mtime := time.Date(2012, 7, 8, 9, 10, 12, 0, time.UTC)
+	edits := []Edit{
+		{Op: EditDelete, Name: "plain.txt"},
+		{Op: EditRename, Name: "secret.txt", NewName: "hidden/secret.txt"},
+		{Op: EditComment, Name: "secret.txt", Comment: "moved"},
+		{Op: EditMtime, Name: "big.txt", Mtime: mtime},
+	}
+	var buf bytes.Buffer
+	if err = rz.Edit(&buf, edits); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rz, after := centralByName(t, buf.Bytes())
+	if len(after) != 2 || after["plain.txt"] != nil {
+		t.Fatalf("edited archive has %v", after)
+	}
+	secret := after["hidden/secret.txt"]
+	if secret == nil || secret.Comment != "moved" {
+		t.Fatalf("renamed entry %+v", secret)
+	}
+	if !after["big.txt"].Mtime.Equal(mtime) {
+		t.Errorf("big.txt has Mtime %v", after["big.txt"].Mtime)
+	}
+	if !bytes.Equal(rawBytes(t, secret), rawBytes(t, before["secret.txt"])) {
+		t.Errorf("secret.txt data changed")
+	}
+	// the encrypted entry kept its descriptor, the local headers still walk
+	local, err := rz.Headers()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var names []string
+	for _, h := range local {
+		names = append(names, h.Name)
+	}
+	if len(names) != 2 || after[names[0]] == nil || after[names[1]] == nil {
+		t.Errorf("Headers() found %v", names)
+	}
+	rz.SetPassword("swordfish")
+	rdr, err := secret.Open()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data, _ := ioutil.ReadAll(rdr); string(data) != "secret contents, not for everyone\n" {
+		t.Errorf("secret.txt reads %q", data)
+	}
+	fmt.Printf("TestEdit fini\n")
+}
+
+// Purpose: replaced contents keep the entry's method, bad edits are refused
+func TestEditReplace(t *testing.T) {
+	fmt.Printf("TestEditReplace start\n")
+	f, err := os.Open("testdata/unix.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	text := strings.Repeat("new readme ", 10)
+	var buf bytes.Buffer
+	err = rz.Edit(&buf, []Edit{{Op: EditReplace, Name: "unix/readme.txt", Data: strings.NewReader(text)}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, after := centralByName(t, buf.Bytes())
+	h := after["unix/readme.txt"]
+	rdr, err := h.Open()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data, _ := ioutil.ReadAll(rdr); string(data) != text {
+		t.Errorf("replaced entry reads %q", data)
+	}
+	if link := after["unix/link.txt"]; link == nil || link.Typeflag != TypeSymlink {
+		t.Errorf("symlink lost its type: %+v", link)
+	}
+
+	if err = rz.Edit(ioutil.Discard, []Edit{{Op: EditDelete, Name: "nope"}}); err != EditNameError {
+		t.Errorf("missing entry: expected EditNameError, got %v", err)
+	}
+	err = rz.Edit(ioutil.Discard, []Edit{{Op: EditRename, Name: "unix/readme.txt", NewName: "unix/bin/hello.sh"}})
+	if err != DuplicateNameError {
+		t.Errorf("rename onto another entry: expected DuplicateNameError, got %v", err)
+	}
+	fmt.Printf("TestEditReplace fini\n")
+}
+
+// Purpose: extra fields survive an edit except zip64, which describes the old
+// archive, and the timestamp of an entry whose Mtime was edited
+func TestEditExtra(t *testing.T) {
+	fmt.Printf("TestEditExtra start\n")
+	ut := []byte{0x55, 0x54, 5, 0, 1, 0x80, 0x1c, 0x01, 0x4f} // mtime 2012-01-02 02:54:56 UTC
+	ux := []byte{0x75, 0x78, 11, 0, 1, 4, 0xe8, 3, 0, 0, 4, 0xe8, 3, 0, 0}
+	z64 := []byte{0x01, 0x00, 8, 0, 5, 0, 0, 0, 0, 0, 0, 0}
+	var orig bytes.Buffer
+	zw := NewWriter(&orig)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		extra := append(append(append([]byte(nil), ut...), z64...), ux...)
+		fw, err := zw.Create(&Header{Name: name, Compress: ZIP_DEFLATED, Extra: extra})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		fmt.Fprintf(fw, "contents of %s\n", name)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rz, before := centralByName(t, orig.Bytes())
+	both := append(append([]byte(nil), ut...), ux...)
+	if !bytes.Equal(before["a.txt"].Extra, both) {
+		t.Errorf("Writer wrote extra % x, expected % x", before["a.txt"].Extra, both)
+	}
+
+	mtime := time.Date(2012, 7, 8, 9, 10, 12, 0, time.UTC)
+	var buf bytes.Buffer
+	err := rz.Edit(&buf, []Edit{
+		{Op: EditRename, Name: "a.txt", NewName: "c.txt"},
+		{Op: EditMtime, Name: "b.txt", Mtime: mtime},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, after := centralByName(t, buf.Bytes())
+	if h := after["c.txt"]; !bytes.Equal(h.Extra, both) || h.ModTime().Unix() != 0x4f011c80 {
+		t.Errorf("renamed entry has extra % x, ModTime %v", h.Extra, h.ModTime())
+	}
+	if h := after["b.txt"]; !bytes.Equal(h.Extra, ux) || !h.ModTime().Equal(mtime) {
+		t.Errorf("entry with new Mtime has extra % x, ModTime %v", h.Extra, h.ModTime())
+	}
+	rdr, err := after["c.txt"].Open()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data, _ := ioutil.ReadAll(rdr); string(data) != "contents of a.txt\n" {
+		t.Errorf("renamed entry reads %q", data)
+	}
+	fmt.Printf("TestEditExtra fini\n")
+}
@@ -0,0 +1,341 @@
+// extract.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Extract writes the archive out to a directory.  Names in a zip archive are
+// just bytes chosen by whoever made it, so every name and every symlink target
+// is checked before anything touches the disk.  An entry that would land
+// outside of dest is refused and reported, the rest of the archive is still
+// extracted.
+
+package zipfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	UnsafePathError  = errors.New("entry name escapes destination directory")
+	UnsafeLinkError  = errors.New("symlink target escapes destination directory")
+	LinkInPathError  = errors.New("refusing to write through a symlink")
+	FileExistsError  = errors.New("file already exists")
+	UnsupportedError = errors.New("entry type not supported for extraction")
+)
+
+// ExtractOptions controls Extract.  A nil *ExtractOptions is the same as
+// the zero value.
+type ExtractOptions struct {
+	Filter    func(*Header) bool // extract only entries where Filter returns true, nil means all
+	Overwrite bool               // replace existing files, otherwise they are left alone and reported as FileExistsError
+	NoLinks   bool               // don't create symlinks at all
+	NoTimes   bool               // leave mtimes as time of extraction
+	NoModes   bool               // ignore stored permissions, use 0644 and 0755
+	JunkPaths bool               // write every file straight into dest, skip directory entries
+	// Replace is asked about each existing file when Overwrite is false,
+	// returning true replaces it.  nil leaves them all alone.
+	Replace func(h *Header, path string) bool
+}
+
+// ExtractResult reports what happened to one entry
+type ExtractResult struct {
+	Header *Header
+	Path   string // where the entry was (or would have been) written, empty if the name was refused
+	Err    error  // nil if the entry was extracted
+}
+
+// Extract writes the contents of the archive below dest, creating dest if
+// needed.  Names that are absolute, carry a drive letter, or use ".." to
+// climb out of dest are refused, backslashes are treated as separators so
+// they can't be used to sneak past the checks.  Symlinks are created last
+// and only if their target stays inside dest.  Nothing is ever written
+// through an existing symlink.
+//
+// The returned error is only for problems with the archive as a whole,
+// each entry's outcome is in its ExtractResult.
+func (r *ZipReader) Extract(dest string, opts *ExtractOptions) ([]ExtractResult, error) {
+	if opts == nil {
+		opts = new(ExtractOptions)
+	}
+	filelist, err := r.CentralHeaders()
+	if err != nil {
+		// damaged directory, fall back to walking the local headers
+		if filelist, err = r.Headers(); err != nil {
+			return nil, err
+		}
+	}
+	dest, err = filepath.Abs(dest)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dest, 0755); err != nil {
+		return nil, err
+	}
+	x := &extractor{dest: dest, opts: opts}
+	results := make([]ExtractResult, 0, len(filelist))
+	var links, dirs []int
+	for _, hdr := range filelist {
+		if opts.Filter != nil && !opts.Filter(hdr) {
+			continue
+		}
+		name := hdr.Name
+		if opts.JunkPaths {
+			if hdr.Typeflag == TypeDir {
+				continue
+			}
+			name = junkPath(name)
+		}
+		res := ExtractResult{Header: hdr}
+		res.Path, res.Err = x.destPath(name)
+		if res.Err == nil {
+			switch hdr.Typeflag {
+			case TypeSymlink:
+				// wait until every file and directory is in place
+				links = append(links, len(results))
+			case TypeDir:
+				res.Err = x.mkdir(res.Path)
+				dirs = append(dirs, len(results))
+			case TypeReg:
+				res.Err = x.writeFile(res.Path, hdr)
+			default:
+				res.Err = UnsupportedError
+			}
+		}
+		results = append(results, res)
+	}
+	for _, ndx := range links {
+		res := &results[ndx]
+		if opts.NoLinks {
+			res.Err = UnsupportedError
+			continue
+		}
+		res.Err = x.symlink(res.Path, res.Header)
+	}
+	// directory times and modes last, writing files into them changes mtime
+	// and a read-only directory would have stopped us writing
+	for i := len(dirs) - 1; i >= 0; i-- {
+		res := &results[dirs[i]]
+		if res.Err == nil {
+			res.Err = x.setAttrs(res.Path, res.Header)
+		}
+	}
+	return results, nil
+}
+
+type extractor struct {
+	dest string
+	opts *ExtractOptions
+}
+
+// destPath turns an entry name into a path below x.dest or refuses it
+func (x *extractor) destPath(name string) (string, error) {
+	name = strings.Replace(name, `\`, "/", -1)
+	if name == "" || strings.IndexByte(name, 0) >= 0 {
+		return "", UnsafePathError
+	}
+	if strings.HasPrefix(name, "/") || hasDriveLetter(name) {
+		return "", UnsafePathError
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return "", UnsafePathError
+		}
+	}
+	path := filepath.Join(x.dest, filepath.FromSlash(name))
+	if !x.inside(path) {
+		return "", UnsafePathError
+	}
+	return path, nil
+}
+
+// junkPath is the last element of name, either kind of slash counts
+func junkPath(name string) string {
+	name = strings.Replace(name, `\`, "/", -1)
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// C:foo and C:/foo both count
+func hasDriveLetter(name string) bool {
+	if len(name) < 2 || name[1] != ':' {
+		return false
+	}
+	c := name[0]
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// inside is true if path is x.dest or below it
+func (x *extractor) inside(path string) bool {
+	rel, err := filepath.Rel(x.dest, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// checkParents makes sure every directory between x.dest and path is a real
+// directory, creating any that are missing.  A symlink anywhere in there could
+// send the write somewhere else entirely.
+func (x *extractor) checkParents(path string) error {
+	rel, err := filepath.Rel(x.dest, filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+	dir := x.dest
+	for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, elem)
+		fi, err := os.Lstat(dir)
+		switch {
+		case os.IsNotExist(err):
+			if err = os.Mkdir(dir, 0755); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case fi.Mode()&os.ModeSymlink != 0:
+			return LinkInPathError
+		case !fi.IsDir():
+			return FileExistsError
+		}
+	}
+	return nil
+}
+
+func (x *extractor) mkdir(path string) error {
+	if err := x.checkParents(path); err != nil {
+		return err
+	}
+	fi, err := os.Lstat(path)
+	if err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		return FileExistsError
+	}
+	return os.Mkdir(path, 0755)
+}
+
+// clear gets an existing non-directory out of the way, or says why not
+func (x *extractor) clear(path string, hdr *Header) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return FileExistsError
+	}
+	if !x.opts.Overwrite && (x.opts.Replace == nil || !x.opts.Replace(hdr, path)) {
+		return FileExistsError
+	}
+	// remove rather than truncate so an existing symlink is never followed
+	return os.Remove(path)
+}
+
+func (x *extractor) writeFile(path string, hdr *Header) error {
+	if err := x.checkParents(path); err != nil {
+		return err
+	}
+	if err := x.clear(path, hdr); err != nil {
+		return err
+	}
+	rdr, err := hdr.Open()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, rdr)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	return x.setAttrs(path, hdr)
+}
+
+func (x *extractor) symlink(path string, hdr *Header) error {
+	target, err := hdr.Linkname()
+	if err != nil {
+		return err
+	}
+	if err = x.checkLink(path, target); err != nil {
+		return err
+	}
+	if err = x.checkParents(path); err != nil {
+		return err
+	}
+	if err = x.clear(path, hdr); err != nil {
+		return err
+	}
+	return os.Symlink(filepath.FromSlash(strings.Replace(target, `\`, "/", -1)), path)
+}
+
+// checkLink refuses link targets that leave x.dest.  The check is done on the
+// text of the target, so ".." is only allowed as a leading run.  Once a
+// component names something in the tree that something may itself be a
+// symlink, and "a/../.." would no longer mean what it looks like.
+func (x *extractor) checkLink(path, target string) error {
+	target = strings.Replace(target, `\`, "/", -1)
+	if target == "" || strings.IndexByte(target, 0) >= 0 {
+		return UnsafeLinkError
+	}
+	if strings.HasPrefix(target, "/") || hasDriveLetter(target) {
+		return UnsafeLinkError
+	}
+	climbing := true
+	for _, elem := range strings.Split(target, "/") {
+		switch elem {
+		case "..":
+			if !climbing {
+				return UnsafeLinkError
+			}
+		case "", ".":
+			// no effect
+		default:
+			climbing = false
+		}
+	}
+	resolved := filepath.Join(filepath.Dir(path), filepath.FromSlash(target))
+	if !x.inside(resolved) {
+		return UnsafeLinkError
+	}
+	return nil
+}
+
+// setAttrs restores permission bits and modification time, the extended
+// timestamp's if there is one (see ModTime).  Setuid, setgid and sticky bits
+// are not restored.
+func (x *extractor) setAttrs(path string, hdr *Header) error {
+	perm := hdr.Mode().Perm()
+	if x.opts.NoModes {
+		perm = 0644
+		if hdr.Typeflag == TypeDir {
+			perm = 0755
+		}
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		return err
+	}
+	if mtime := hdr.ModTime(); !x.opts.NoTimes && !mtime.IsZero() {
+		if err := os.Chtimes(path, time.Now(), mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
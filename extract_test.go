@@ -0,0 +1,257 @@
+// extract_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Purpose: Extract() a hostile archive, only the good/ entries may be written
+// and nothing at all may appear outside of dest
+func TestExtractSlip(t *testing.T) {
+	fmt.Printf("TestExtractSlip start\n")
+	const testfile = "testdata/slip.zip"
+	top, err := ioutil.TempDir("", "zipslip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(top)
+	dest := filepath.Join(top, "a", "b")
+
+	f, err := os.Open(testfile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	results, err := rz.Extract(dest, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := map[string]error{
+		"good/":        nil,
+		"good/ok.txt":  nil,
+		"good/run.sh":  nil,
+		"good/in":      nil,
+		"good/up":      nil,
+		"../evil.txt":  UnsafePathError,
+		"/abs.txt":     UnsafePathError,
+		"C:/drive.txt": UnsafePathError,
+		`..\back.txt`:  UnsafePathError,
+		"good/out":     UnsafeLinkError,
+		"good/abslink": UnsafeLinkError,
+		"good/sneaky":  UnsafeLinkError,
+		"esc":          UnsafeLinkError,
+		"esc/pwn.txt":  nil, // esc never became a link so this lands in dest/esc
+	}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+	for _, res := range results {
+		if res.Err != want[res.Header.Name] {
+			t.Errorf("%s: got %v, want %v", res.Header.Name, res.Err, want[res.Header.Name])
+		}
+	}
+	// the only thing in top should be the a/b/... tree
+	err = filepath.Walk(top, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != top && path != filepath.Join(top, "a") && !(&extractor{dest: dest}).inside(path) {
+			t.Errorf("found %s outside of dest", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(dest, "good", "run.sh"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fi.Mode().Perm() != 0755 {
+		t.Errorf("run.sh mode %v, want 0755", fi.Mode())
+	}
+	if fi.ModTime().Year() != 2012 {
+		t.Errorf("run.sh mtime %v not restored", fi.ModTime())
+	}
+	target, err := os.Readlink(filepath.Join(dest, "good", "up"))
+	if err != nil || target != "../good/ok.txt" {
+		t.Errorf("good/up link %q, %v", target, err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dest, "good", "in"))
+	if err != nil || string(data) != "safe\n" {
+		t.Errorf("reading through good/in got %q, %v", data, err)
+	}
+	fmt.Printf("TestExtractSlip fini\n")
+}
+
+// Purpose: a second Extract() leaves files alone unless Overwrite is set,
+// and a planted symlink is replaced rather than followed
+func TestExtractOverwrite(t *testing.T) {
+	fmt.Printf("TestExtractOverwrite start\n")
+	const testfile = "testdata/unix.zip"
+	dest, err := ioutil.TempDir("", "zipover")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dest)
+	outside := filepath.Join(dest, "outside.txt")
+	if err = ioutil.WriteFile(outside, []byte("keep me\n"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = os.MkdirAll(filepath.Join(dest, "unix"), 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = os.Symlink(outside, filepath.Join(dest, "unix", "readme.txt")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	f, err := os.Open(testfile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	results, err := rz.Extract(dest, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, res := range results {
+		if res.Header.Name == "unix/readme.txt" && res.Err != FileExistsError {
+			t.Errorf("%s: got %v, want FileExistsError", res.Header.Name, res.Err)
+		}
+	}
+	results, err = rz.Extract(dest, &ExtractOptions{Overwrite: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			t.Errorf("%s: Unexpected error: %v", res.Header.Name, res.Err)
+		}
+	}
+	data, err := ioutil.ReadFile(outside)
+	if err != nil || string(data) != "keep me\n" {
+		t.Errorf("file outside dest was changed: %q, %v", data, err)
+	}
+	data, err = ioutil.ReadFile(filepath.Join(dest, "unix", "readme.txt"))
+	if err != nil || string(data) != "plain text file\n" {
+		t.Errorf("readme.txt got %q, %v", data, err)
+	}
+	fmt.Printf("TestExtractOverwrite fini\n")
+}
+
+// Purpose: JunkPaths flattens the tree and Replace decides, file by file,
+// what happens to files that are already there
+func TestExtractJunkReplace(t *testing.T) {
+	fmt.Printf("TestExtractJunkReplace start\n")
+	dest, err := ioutil.TempDir("", "zipjunk")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dest)
+	f, err := os.Open("testdata/unix.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, name := range []string{"readme.txt", "hello.sh"} {
+		if err = ioutil.WriteFile(filepath.Join(dest, name), []byte("old\n"), 0644); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	var asked []string
+	opts := &ExtractOptions{JunkPaths: true, NoLinks: true}
+	opts.Replace = func(h *Header, path string) bool {
+		asked = append(asked, filepath.Base(path))
+		return h.Name == "unix/bin/hello.sh"
+	}
+	results, err := rz.Extract(dest, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(results) != 3 { // the two directories are skipped
+		t.Errorf("expected 3 results, got %d", len(results))
+	}
+	for _, res := range results {
+		if filepath.Dir(res.Path) != dest {
+			t.Errorf("%s landed in %s", res.Header.Name, res.Path)
+		}
+	}
+	if len(asked) != 2 {
+		t.Errorf("Replace asked about %v", asked)
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(dest, "readme.txt"))
+	if string(data) != "old\n" {
+		t.Errorf("readme.txt was replaced: %q", data)
+	}
+	data, _ = ioutil.ReadFile(filepath.Join(dest, "hello.sh"))
+	if string(data) == "old\n" {
+		t.Errorf("hello.sh was not replaced")
+	}
+	fmt.Printf("TestExtractJunkReplace fini\n")
+}
+
+// Purpose: extracted files get the extended timestamp's mtime when there is
+// one, the MSDOS time otherwise
+func TestExtractTimes(t *testing.T) {
+	fmt.Printf("TestExtractTimes start\n")
+	dir, err := ioutil.TempDir("", "extract")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dosTime := time.Date(2012, 3, 4, 5, 6, 8, 0, time.UTC)
+	ut := []byte{0x55, 0x54, 5, 0, 1, 0x81, 0x1c, 0x01, 0x4f} // 2012-01-02 02:54:57 UTC, an odd second
+	var buf bytes.Buffer
+	zw := NewWriter(&buf)
+	for _, h := range []*Header{{Name: "ut.txt", Mtime: dosTime, Extra: ut}, {Name: "dos.txt", Mtime: dosTime}} {
+		fw, err := zw.Create(h)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		fmt.Fprintf(fw, "%s\n", h.Name)
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rz, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = rz.Extract(dir, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for name, want := range map[string]time.Time{"ut.txt": time.Unix(0x4f011c81, 0), "dos.txt": dosTime} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !fi.ModTime().Equal(want) {
+			t.Errorf("%s: mtime %v, expected %v", name, fi.ModTime(), want)
+		}
+	}
+	fmt.Printf("TestExtractTimes fini\n")
+}
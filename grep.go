@@ -0,0 +1,201 @@
+// grep.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Searching the contents of an archive's entries for a regular expression,
+// what Info-ZIP's zipgrep script does by running unzip -p into egrep once
+// per entry.  Entries are expanded and searched several at a time, results
+// still come back in archive order.
+
+package zipfile
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// GrepMatch is a line that matched, or a binary entry that did somewhere
+type GrepMatch struct {
+	Path   string  // entry name, after the archives holding it if nested
+	Header *Header // the entry
+	Line   int     // line number counting from 1, 0 for a binary entry
+	Text   string  // the line without its newline, empty for a binary entry
+	Binary bool    // entry isn't text, grep would say "Binary file matches"
+}
+
+// GrepOptions configure Grep, nil searches every entry of the archive
+// itself with one worker per CPU
+type GrepOptions struct {
+	Selector *Selector // entries to search, matched against the name in their own archive
+	Nested   bool      // search the entries of archives inside the archive too, see Walk
+	Name     string    // put in front of every Path, usually the archive's file name
+	Workers  int       // entries searched at once, 0 means one per CPU
+}
+
+// grepPeek is how much of an entry decides whether it is text, as grep
+// looks only at the start of a file
+const grepPeek = 32 * 1024
+
+// grepResult is what searching one entry found
+type grepResult struct {
+	matches []GrepMatch
+	err     error
+}
+
+// Grep searches every selected entry for re and calls fn with each match, in
+// archive order and line order.  Directories, symlinks and, when Nested,
+// archives are not searched themselves.  An entry that isn't text (its first
+// 32K hold a NUL or aren't UTF-8) gives a single Binary match if re matches
+// anywhere in it.  Grep stops at the first error from reading an entry or
+// from fn and returns it.
+func (r *ZipReader) Grep(re *regexp.Regexp, opts *GrepOptions, fn func(GrepMatch) error) error {
+	if opts == nil {
+		opts = &GrepOptions{}
+	}
+	var hdrs []*Header
+	var paths []string
+	add := func(p string, h *Header) {
+		if h.Typeflag == TypeReg && opts.Selector.Match(h) {
+			if opts.Name == "" {
+				p = strings.TrimPrefix(p, "!/")
+			}
+			hdrs = append(hdrs, h)
+			paths = append(paths, p)
+		}
+	}
+	if opts.Nested {
+		err := r.Walk(opts.Name, func(p string, h *Header, err error) error {
+			if err == nil && !h.looksLikeZip() {
+				add(p, h)
+			}
+			return nil // archives that can't be opened are just not searched
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		filelist, err := r.CentralHeaders()
+		if err != nil {
+			return err
+		}
+		for _, h := range filelist {
+			add(opts.Name+"!/"+h.Name, h)
+		}
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	results := make([]chan grepResult, len(hdrs))
+	for ndx := range results {
+		results[ndx] = make(chan grepResult, 1)
+	}
+	jobs := make(chan int)
+	done := make(chan struct{})
+	defer close(done)
+	var serial sync.Mutex // for headers whose reader can't be shared
+	for w := 0; w < workers; w++ {
+		go func() {
+			for ndx := range jobs {
+				m, err := grepEntry(re, hdrs[ndx], paths[ndx], &serial)
+				results[ndx] <- grepResult{m, err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for ndx := range hdrs {
+			select {
+			case jobs <- ndx:
+			case <-done:
+				return
+			}
+		}
+	}()
+	for ndx := range hdrs {
+		res := <-results[ndx]
+		if res.err != nil {
+			return res.err
+		}
+		for _, m := range res.matches {
+			if err := fn(m); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// grepEntry searches one entry line by line, or as a whole with MatchReader
+// if it is binary.  Open() expands the entry into memory before anything is
+// read from it (see Paranoid in the package doc), so each worker holds one
+// whole entry however it is searched.  Open seeks and reads the archive's
+// reader, so each worker gets a reader of its own if the archive's can
+// ReadAt, and takes turns with serial otherwise.
+func grepEntry(re *regexp.Regexp, h *Header, path string, serial *sync.Mutex) ([]GrepMatch, error) {
+	nh := *h
+	if ra, ok := h.Hreader.(io.ReaderAt); ok {
+		nh.Hreader = io.NewSectionReader(ra, 0, 1<<62)
+	} else {
+		serial.Lock()
+		defer serial.Unlock()
+	}
+	rdr, err := nh.Open()
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(rdr, grepPeek)
+	head, err := br.Peek(grepPeek)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if looksBinary(head, err == nil) {
+		if re.MatchReader(br) {
+			return []GrepMatch{{Path: path, Header: h, Binary: true}}, nil
+		}
+		return nil, nil
+	}
+	var matches []GrepMatch
+	for line := 1; ; line++ {
+		text, err := br.ReadBytes('\n')
+		if len(text) > 0 {
+			text = bytes.TrimSuffix(bytes.TrimSuffix(text, []byte("\n")), []byte("\r"))
+			if re.Match(text) {
+				matches = append(matches, GrepMatch{Path: path, Header: h, Line: line, Text: string(text)})
+			}
+		}
+		if err == io.EOF {
+			return matches, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// looksBinary is true if p, the start of an entry, has a NUL or isn't UTF-8.
+// If more follows a rune cut off at the end of p doesn't count.
+func looksBinary(p []byte, more bool) bool {
+	if bytes.IndexByte(p, 0) >= 0 {
+		return true
+	}
+	if more {
+		for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(p[i]) {
+				p = p[:i]
+				break
+			}
+		}
+	}
+	return !utf8.Valid(p)
+}
@@ -0,0 +1,127 @@
+// grep_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Purpose: matches come back in order whatever the number of workers, with
+// binary entries, nested archives and the selector handled
+func TestGrep(t *testing.T) {
+	fmt.Printf("TestGrep start\n")
+	jar := nestedZip(t, "conf/app.properties", ZIP_DEFLATED, []byte("name=app\npassword=hunter2\n"))
+	archive := nestedZip(t,
+		"a.txt", ZIP_DEFLATED, []byte("first\nno password here\r\nthird"),
+		"dir/", ZIP_STORED, []byte{},
+		"b.bin", ZIP_STORED, []byte("password\x00\x01\x02"),
+		"lib/app.jar", ZIP_STORED, jar,
+		"c.txt", ZIP_STORED, []byte("password: see b.bin\n"))
+	re := regexp.MustCompile("pass(word)?")
+	type found struct {
+		path   string
+		line   int
+		text   string
+		binary bool
+	}
+	all := []found{
+		{"a.txt", 2, "no password here", false},
+		{"b.bin", 0, "", true},
+		{"lib/app.jar", 0, "", true}, // stored, so the jar's bytes hold the text
+		{"c.txt", 1, "password: see b.bin", false},
+	}
+	nested := []found{all[0], all[1], {"x.zip!/lib/app.jar!/conf/app.properties", 2, "password=hunter2", false}, all[3]}
+	nested[0].path, nested[1].path, nested[3].path = "x.zip!/a.txt", "x.zip!/b.bin", "x.zip!/c.txt"
+	txt, _ := Glob("*.txt")
+	tests := []struct {
+		opts GrepOptions
+		want []found
+	}{
+		{GrepOptions{}, all},
+		{GrepOptions{Nested: true, Name: "x.zip"}, nested},
+		{GrepOptions{Selector: &Selector{Include: []Pattern{txt}}}, []found{all[0], all[3]}},
+	}
+	for ndx, tt := range tests {
+		for _, workers := range []int{1, 4} {
+			for _, rs := range []io.ReadSeeker{bytes.NewReader(archive), seekOnly{bytes.NewReader(archive)}} {
+				rz, err := NewReader(rs)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				opts := tt.opts
+				opts.Workers = workers
+				var got []found
+				err = rz.Grep(re, &opts, func(m GrepMatch) error {
+					got = append(got, found{m.Path, m.Line, m.Text, m.Binary})
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("test %d, %d workers: got %v, expected %v", ndx, workers, got, tt.want)
+				}
+			}
+		}
+	}
+
+	// an error from fn stops the search
+	rz, err := NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	calls := 0
+	err = rz.Grep(re, nil, func(m GrepMatch) error {
+		calls++
+		return io.ErrShortWrite
+	})
+	if err != io.ErrShortWrite || calls != 1 {
+		t.Errorf("got %v after %d calls, expected io.ErrShortWrite after 1", err, calls)
+	}
+	fmt.Printf("TestGrep fini\n")
+}
+
+// Purpose: entries longer than the part that decides text or binary, with a
+// rune across that boundary and matches past it
+func TestGrepLong(t *testing.T) {
+	fmt.Printf("TestGrepLong start\n")
+	filler := strings.Repeat("x", grepPeek-1) + "\u00e9\n" // é's two bytes straddle the boundary
+	archive := nestedZip(t,
+		"long.txt", ZIP_DEFLATED, []byte(filler+"middle\nneedle at the end"),
+		"late.bin", ZIP_DEFLATED, []byte(filler+"needle\x00"), // NUL too late to make it binary
+		"early.bin", ZIP_DEFLATED, []byte("\x00"+filler+"needle"))
+	rz, err := NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var got []string
+	err = rz.Grep(regexp.MustCompile("needle|\u00e9"), nil, func(m GrepMatch) error {
+		got = append(got, fmt.Sprintf("%s:%d:%v:%d", m.Path, m.Line, m.Binary, len(m.Text)))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []string{
+		fmt.Sprintf("long.txt:1:false:%d", grepPeek+1),
+		"long.txt:3:false:17",
+		fmt.Sprintf("late.bin:1:false:%d", grepPeek+1),
+		"late.bin:2:false:7",
+		"early.bin:0:true:0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, expected %v", got, want)
+	}
+	fmt.Printf("TestGrepLong fini\n")
+}
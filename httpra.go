@@ -0,0 +1,249 @@
+// httpra.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// HTTPReaderAt lets the reader work on an archive that lives on a web server.
+// Each ReadAt turns into HTTP Range requests for whole blocks, and blocks are
+// kept in a small cache that drops the least recently used, so
+// CentralHeaders() plus one Open() reads the tail of the archive and one
+// entry instead of the whole thing.
+
+package zipfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultBlockSize = 16 * 1024
+	DefaultMaxBlocks = 256 // 4MB of cache with the default block size
+)
+
+var (
+	RangeUnsupportedError = errors.New("server does not support Range requests")
+	ContentRangeError     = errors.New("bad or missing Content-Range in response")
+)
+
+// HTTPReaderAt is an io.ReaderAt for a URL.  Change BlockSize, MaxBlocks and
+// Client before the first ReadAt if the defaults don't suit.
+type HTTPReaderAt struct {
+	URL       string
+	Client    *http.Client
+	BlockSize int64
+	MaxBlocks int
+	Requests  int // range requests made so far, handy for tuning BlockSize
+
+	size  int64
+	mu    sync.Mutex
+	cache map[int64][]byte // block number -> contents
+	order []int64          // block numbers least recently used first, for eviction
+}
+
+// NewHTTPReaderAt checks that url answers Range requests and learns its
+// size.  An empty file has no byte 0 to ask for, the server's 416 (or 200
+// with nothing in it) gives it size 0.
+func NewHTTPReaderAt(url string) (*HTTPReaderAt, error) {
+	h := &HTTPReaderAt{
+		URL:       url,
+		Client:    http.DefaultClient,
+		BlockSize: DefaultBlockSize,
+		MaxBlocks: DefaultMaxBlocks,
+		cache:     make(map[int64][]byte),
+	}
+	_, size, err := h.fetch(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	h.size = size
+	return h, nil
+}
+
+// OpenURL is NewHTTPReaderAt and NewReader in one step.  Use CentralHeaders()
+// on the result, Headers() would walk every local header over the network.
+func OpenURL(url string) (*ZipReader, error) {
+	h, err := NewHTTPReaderAt(url)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(io.NewSectionReader(h, 0, h.Size()))
+}
+
+// Size returns the length of the remote file
+func (h *HTTPReaderAt) Size() int64 {
+	return h.size
+}
+
+func (h *HTTPReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= h.size {
+		return 0, io.EOF
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	end := off + int64(len(p))
+	if end > h.size {
+		end = h.size
+	}
+	first, last := off/h.BlockSize, (end-1)/h.BlockSize
+	// fetch each run of missing blocks with a single request
+	for b := first; b <= last; b++ {
+		if _, ok := h.cache[b]; ok {
+			continue
+		}
+		run := b
+		for run+1 <= last {
+			if _, ok := h.cache[run+1]; ok {
+				break
+			}
+			run++
+		}
+		if err := h.fill(b, run); err != nil {
+			return 0, err
+		}
+		b = run
+	}
+	n := 0
+	for b := first; b <= last; b++ {
+		h.touch(b)
+		blk := h.cache[b]
+		start := int64(0)
+		if b == first {
+			start = off - b*h.BlockSize
+		}
+		n += copy(p[n:], blk[start:])
+	}
+	// blocks just read may have pushed out ones we needed, so evict only now
+	h.evict()
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fill reads blocks first thru last into the cache
+func (h *HTTPReaderAt) fill(first, last int64) error {
+	start := first * h.BlockSize
+	end := (last+1)*h.BlockSize - 1
+	if end >= h.size {
+		end = h.size - 1
+	}
+	data, _, err := h.fetch(start, end)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != end-start+1 {
+		return io.ErrUnexpectedEOF
+	}
+	for b := first; b <= last; b++ {
+		lo := (b - first) * h.BlockSize
+		hi := lo + h.BlockSize
+		if hi > int64(len(data)) {
+			hi = int64(len(data))
+		}
+		h.cache[b] = data[lo:hi]
+		h.order = append(h.order, b)
+	}
+	return nil
+}
+
+// touch moves block b to the most recently used end of order
+func (h *HTTPReaderAt) touch(b int64) {
+	for ndx, o := range h.order {
+		if o == b {
+			h.order = append(append(h.order[:ndx:ndx], h.order[ndx+1:]...), b)
+			return
+		}
+	}
+}
+
+// evict drops least recently used blocks until there are MaxBlocks left
+func (h *HTTPReaderAt) evict() {
+	for len(h.cache) > h.MaxBlocks && len(h.order) > 0 {
+		delete(h.cache, h.order[0])
+		h.order = h.order[1:]
+	}
+}
+
+// fetch asks for bytes start thru end inclusive, returns them and the total
+// size of the remote file from Content-Range.  A 416 means start is past the
+// end, "bytes */size" says where the end is, and no size at all means empty.
+// A 200 with Content-Length 0 is empty too.
+func (h *HTTPReaderAt) fetch(start, end int64) ([]byte, int64, error) {
+	req, err := http.NewRequest("GET", h.URL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	h.Requests++
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		if resp.ContentLength == 0 {
+			return nil, 0, nil // empty, some servers don't bother with ranges
+		}
+		return nil, 0, RangeUnsupportedError
+	}
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		cr := resp.Header.Get("Content-Range")
+		if cr == "" {
+			return nil, 0, nil
+		}
+		if !strings.HasPrefix(cr, "bytes */") {
+			return nil, 0, ContentRangeError
+		}
+		size, err := strconv.ParseInt(cr[len("bytes */"):], 10, 64)
+		if err != nil || size > start {
+			return nil, 0, ContentRangeError
+		}
+		return nil, size, nil
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, 0, fmt.Errorf("%s: %s", h.URL, resp.Status)
+	}
+	size, err := parseContentRange(resp.Header.Get("Content-Range"), start)
+	if err != nil {
+		return nil, 0, err
+	}
+	data := make([]byte, end-start+1)
+	n, err := io.ReadFull(resp.Body, data)
+	if err == io.ErrUnexpectedEOF && start+int64(n) == size {
+		err = nil // asked past the end, fine
+	}
+	return data[:n], size, err
+}
+
+// parseContentRange checks "bytes start-end/size" and returns size
+func parseContentRange(cr string, start int64) (int64, error) {
+	if !strings.HasPrefix(cr, "bytes ") {
+		return 0, ContentRangeError
+	}
+	cr = cr[len("bytes "):]
+	slash := strings.IndexByte(cr, '/')
+	dash := strings.IndexByte(cr, '-')
+	if slash < 0 || dash < 0 || dash > slash {
+		return 0, ContentRangeError
+	}
+	got, err := strconv.ParseInt(cr[:dash], 10, 64)
+	if err != nil || got != start {
+		return 0, ContentRangeError
+	}
+	size, err := strconv.ParseInt(cr[slash+1:], 10, 64)
+	if err != nil {
+		return 0, ContentRangeError // "*" means the server doesn't know, no use to us
+	}
+	return size, nil
+}
@@ -0,0 +1,190 @@
+// httpra_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// countingWriter tallies how much the test server sends
+type countingWriter struct {
+	http.ResponseWriter
+	n *int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	*c.n += int64(len(p))
+	return c.ResponseWriter.Write(p)
+}
+
+// Purpose: list a remote archive and extract one entry without
+// transferring more than a small part of it
+func TestHTTPReaderAt(t *testing.T) {
+	fmt.Printf("TestHTTPReaderAt start\n")
+	const testfile = "testdata/phpBB.zip"
+	const wanted = "phpBB2/admin/admin_board.php"
+	archive, err := ioutil.ReadFile(testfile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var sent int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.ServeContent(countingWriter{w, &sent}, req, "phpBB.zip", time.Time{}, bytes.NewReader(archive))
+	}))
+	defer ts.Close()
+
+	ra, err := NewHTTPReaderAt(ts.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ra.Size() != int64(len(archive)) {
+		t.Fatalf("remote size %d, want %d", ra.Size(), len(archive))
+	}
+	rz, err := NewReader(io.NewSectionReader(ra, 0, ra.Size()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var got []byte
+	for _, hdr := range filelist {
+		if hdr.Name != wanted {
+			continue
+		}
+		rdr, err := hdr.Open()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		got, _ = ioutil.ReadAll(rdr)
+	}
+	want := contentsOf(t, testfile, wanted)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s: remote contents differ (%d bytes, want %d)", wanted, len(got), len(want))
+	}
+	fmt.Printf("read %d of %d bytes in %d requests\n", sent, len(archive), ra.Requests)
+	if sent > int64(len(archive))/5 {
+		t.Errorf("transferred %d bytes of a %d byte archive", sent, len(archive))
+	}
+	fmt.Printf("TestHTTPReaderAt fini\n")
+}
+
+// Purpose: a server that ignores Range gets a clear error
+func TestHTTPNoRange(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("PK no ranges here"))
+	}))
+	defer ts.Close()
+	if _, err := OpenURL(ts.URL); err != RangeUnsupportedError {
+		t.Errorf("expected RangeUnsupportedError, got %v", err)
+	}
+}
+
+// Purpose: an empty remote file answers the size probe with 416, or with
+// a 200 and nothing in it, either is size 0 and not an error
+func TestHTTPEmpty(t *testing.T) {
+	fmt.Printf("TestHTTPEmpty start\n")
+	handlers := []http.HandlerFunc{
+		func(w http.ResponseWriter, req *http.Request) {
+			http.ServeContent(w, req, "empty.zip", time.Time{}, bytes.NewReader(nil))
+		},
+		func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Range", "bytes */0")
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		},
+	}
+	for ndx, handler := range handlers {
+		ts := httptest.NewServer(handler)
+		ra, err := NewHTTPReaderAt(ts.URL)
+		ts.Close()
+		if err != nil {
+			t.Fatalf("server %d: Unexpected error: %v", ndx, err)
+		}
+		if ra.Size() != 0 {
+			t.Errorf("server %d: remote size %d, want 0", ndx, ra.Size())
+		}
+		if n, err := ra.ReadAt(make([]byte, 10), 0); n != 0 || err != io.EOF {
+			t.Errorf("server %d: ReadAt gave %d, %v", ndx, n, err)
+		}
+	}
+	fmt.Printf("TestHTTPEmpty fini\n")
+}
+
+// Purpose: the cache keeps the blocks read most recently, a block read
+// again survives one that was only read once
+func TestHTTPCacheLRU(t *testing.T) {
+	fmt.Printf("TestHTTPCacheLRU start\n")
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.ServeContent(w, req, "digits", time.Time{}, bytes.NewReader(data))
+	}))
+	defer ts.Close()
+	ra, err := NewHTTPReaderAt(ts.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ra.BlockSize, ra.MaxBlocks = 10, 2
+	buf := make([]byte, 10)
+	for _, off := range []int64{0, 10, 0, 20} { // block 1 is least recently used when 2 comes in
+		if _, err := ra.ReadAt(buf, off); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	before := ra.Requests
+	if _, err := ra.ReadAt(buf, 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ra.Requests != before {
+		t.Errorf("block 0 was evicted")
+	}
+	if _, err := ra.ReadAt(buf, 10); err != nil || ra.Requests != before+1 {
+		t.Errorf("block 1 should have been evicted: %d requests, %v", ra.Requests-before, err)
+	}
+	if !bytes.Equal(buf, data[10:20]) {
+		t.Errorf("read %q", buf)
+	}
+	fmt.Printf("TestHTTPCacheLRU fini\n")
+}
+
+// contentsOf reads one entry the ordinary way, from a local file
+func contentsOf(t *testing.T, testfile, name string) []byte {
+	f, err := os.Open(testfile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.Headers()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, hdr := range filelist {
+		if hdr.Name == name {
+			rdr, err := hdr.Open()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			data, _ := ioutil.ReadAll(rdr)
+			return data
+		}
+	}
+	t.Fatalf("%s not found in %s", name, testfile)
+	return nil
+}
@@ -0,0 +1,145 @@
+// limits.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Decompression bomb safeguards.  The sizes in a zip header are whatever the
+// archive's author wanted them to be, so the limits are checked against the
+// header claims up front (cheap rejection) and again against the bytes that
+// actually come out of the decompressor (the check that matters).
+
+package zipfile
+
+import (
+	"bytes"
+	"io"
+	"sync/atomic"
+)
+
+// Limits guards against hostile archives.  A zero field means no limit.
+type Limits struct {
+	MaxEntrySize int64   // expanded bytes in any one entry
+	MaxTotalSize int64   // expanded bytes over every entry opened from a reader, nested readers included
+	MaxRatio     float64 // expanded bytes / compressed bytes for any one entry
+	MaxEntries   int     // entries in one archive
+	MaxNesting   int     // depth of archives opened with OpenNested
+}
+
+// DefaultLimits is copied into every new ZipReader, change it before calling
+// NewReader or use SetLimits on the reader afterward.  The zero value keeps
+// the old unlimited behavior.
+var DefaultLimits Limits
+
+// LimitError is returned when an archive trips one of the Limits.
+// Callers screening uploads can test for it with a type assertion and
+// reject the archive without caring which limit it was.
+type LimitError struct {
+	Limit string // name of the Limits field that was exceeded
+	Name  string // entry being read, empty for archive-wide limits
+}
+
+func (e *LimitError) Error() string {
+	if e.Name == "" {
+		return "archive exceeds " + e.Limit
+	}
+	return e.Name + ": exceeds " + e.Limit
+}
+
+// SetLimits replaces the reader's limits, which start as a copy of DefaultLimits
+func (r *ZipReader) SetLimits(l Limits) {
+	r.limits = l
+}
+
+// checkClaims rejects an entry on the strength of its header alone
+func (r *ZipReader) checkClaims(h *Header) error {
+	l := &r.limits
+	if l.MaxEntrySize > 0 && h.Size > l.MaxEntrySize {
+		return &LimitError{"MaxEntrySize", h.Name}
+	}
+	if l.MaxTotalSize > 0 && atomic.LoadInt64(r.expanded)+h.Size > l.MaxTotalSize {
+		return &LimitError{"MaxTotalSize", h.Name}
+	}
+	if l.MaxRatio > 0 && h.Size > 0 && float64(h.Size) > l.MaxRatio*float64(h.SizeCompr) {
+		return &LimitError{"MaxRatio", h.Name}
+	}
+	return nil
+}
+
+// countEntry is called as each header is added to a listing
+func (r *ZipReader) countEntry(n int) error {
+	if r.limits.MaxEntries > 0 && n > r.limits.MaxEntries {
+		return &LimitError{"MaxEntries", ""}
+	}
+	return nil
+}
+
+// limitReader counts what the decompressor produces and stops as soon as
+// any limit is crossed, whatever the header said
+type limitReader struct {
+	rdr       io.Reader
+	limits    *Limits
+	expanded  *int64 // running total for MaxTotalSize
+	name      string
+	comprSize *int64 // compressed bytes, may still be growing when streaming
+	n         int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.rdr.Read(p)
+	l.n += int64(n)
+	lim := l.limits
+	if lim.MaxEntrySize > 0 && l.n > lim.MaxEntrySize {
+		return n, &LimitError{"MaxEntrySize", l.name}
+	}
+	if lim.MaxRatio > 0 && float64(l.n) > lim.MaxRatio*float64(*l.comprSize) {
+		return n, &LimitError{"MaxRatio", l.name}
+	}
+	total := atomic.AddInt64(l.expanded, int64(n))
+	if lim.MaxTotalSize > 0 && total > lim.MaxTotalSize {
+		return n, &LimitError{"MaxTotalSize", l.name}
+	}
+	return n, err
+}
+
+// OpenNested treats the entry as a zip archive in its own right.  The new
+// reader shares this reader's limits, total size count and password, and is
+// one level deeper for MaxNesting.  A stored entry is read where it lies in this
+// archive, anything else is expanded into memory first.
+func (r *ZipReader) OpenNested(h *Header) (*ZipReader, error) {
+	if r.limits.MaxNesting > 0 && r.depth+1 > r.limits.MaxNesting {
+		return nil, &LimitError{"MaxNesting", h.Name}
+	}
+	var rs io.ReadSeeker
+	if h.Compress == ZIP_STORED && !h.Encrypted() && h.Hreader != nil {
+		if err := r.checkClaims(h); err != nil {
+			return nil, err
+		}
+		off, err := h.DataOffset()
+		if err != nil {
+			return nil, err
+		}
+		rs = newSection(h.Hreader, off, h.SizeCompr)
+		atomic.AddInt64(r.expanded, h.Size) // as if Open had read it
+	} else {
+		rdr, err := h.Open()
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if _, err = io.Copy(&buf, rdr); err != nil {
+			return nil, err
+		}
+		rs = bytes.NewReader(buf.Bytes())
+	}
+	nz, err := NewReader(rs)
+	if err != nil {
+		return nil, err
+	}
+	nz.limits = r.limits
+	nz.expanded = r.expanded
+	nz.depth = r.depth + 1
+	nz.password = r.password
+	return nz, nil
+}
@@ -0,0 +1,178 @@
+// limits_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func openBomb(t *testing.T, l Limits) (*os.File, map[string]*Header, *ZipReader) {
+	f, err := os.Open("testdata/bomb.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rz.SetLimits(l)
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	hdrs := make(map[string]*Header)
+	for _, hdr := range filelist {
+		hdrs[hdr.Name] = hdr
+	}
+	return f, hdrs, rz
+}
+
+func limitHit(err error, limit string) bool {
+	le, ok := err.(*LimitError)
+	return ok && le.Limit == limit
+}
+
+// Purpose: per-entry size and ratio limits, both from the header claims
+// and from what the decompressor actually produces
+func TestEntryLimits(t *testing.T) {
+	fmt.Printf("TestEntryLimits start\n")
+	f, hdrs, _ := openBomb(t, Limits{MaxEntrySize: 4096})
+	defer f.Close()
+	if _, err := hdrs["zeros"].Open(); !limitHit(err, "MaxEntrySize") {
+		t.Errorf("zeros: expected MaxEntrySize, got %v", err)
+	}
+	// liar claims 100 bytes so only the streaming check can catch it
+	if _, err := hdrs["liar"].Open(); !limitHit(err, "MaxEntrySize") {
+		t.Errorf("liar: expected MaxEntrySize, got %v", err)
+	}
+	if _, err := hdrs["small.txt"].Open(); err != nil {
+		t.Errorf("small.txt: Unexpected error: %v", err)
+	}
+
+	f2, hdrs, _ := openBomb(t, Limits{MaxRatio: 50})
+	defer f2.Close()
+	for _, name := range []string{"zeros", "liar"} {
+		if _, err := hdrs[name].Open(); !limitHit(err, "MaxRatio") {
+			t.Errorf("%s: expected MaxRatio, got %v", name, err)
+		}
+	}
+	fmt.Printf("TestEntryLimits fini\n")
+}
+
+// Purpose: total size and entry count limits apply across the whole archive
+func TestArchiveLimits(t *testing.T) {
+	fmt.Printf("TestArchiveLimits start\n")
+	f, hdrs, _ := openBomb(t, Limits{MaxTotalSize: 1<<20 + 10})
+	defer f.Close()
+	if _, err := hdrs["zeros"].Open(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := hdrs["small.txt"].Open(); !limitHit(err, "MaxTotalSize") {
+		t.Errorf("small.txt: expected MaxTotalSize, got %v", err)
+	}
+
+	f2, err := os.Open("testdata/bomb.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f2.Close()
+	rz, err := NewReader(f2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rz.SetLimits(Limits{MaxEntries: 2})
+	if _, err = rz.CentralHeaders(); !limitHit(err, "MaxEntries") {
+		t.Errorf("CentralHeaders: expected MaxEntries, got %v", err)
+	}
+	if _, err = rz.Headers(); !limitHit(err, "MaxEntries") {
+		t.Errorf("Headers: expected MaxEntries, got %v", err)
+	}
+	fmt.Printf("TestArchiveLimits fini\n")
+}
+
+// Purpose: OpenNested counts depth and shares the running total
+func TestNestingLimit(t *testing.T) {
+	fmt.Printf("TestNestingLimit start\n")
+	inner, err := ioutil.ReadFile("testdata/stuf.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// stuf.zip stored inside a zip, built by hand so the test needs no writer
+	outer := storedZip("stuf.zip", inner)
+	rz, err := NewReader(outer)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rz.SetLimits(Limits{MaxNesting: 1})
+	filelist, err := rz.Headers()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	nz, err := rz.OpenNested(filelist[0])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	nested, err := nz.Headers()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = nz.OpenNested(nested[0]); !limitHit(err, "MaxNesting") {
+		t.Errorf("expected MaxNesting, got %v", err)
+	}
+	if _, err = nested[0].Open(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *rz.expanded != int64(len(inner))+nested[0].Size {
+		t.Errorf("outer reader total %d doesn't include nested entry", *rz.expanded)
+	}
+	fmt.Printf("TestNestingLimit fini\n")
+}
+
+// storedZip builds a single entry archive with method 0 in memory
+func storedZip(name string, data []byte) *bytes.Reader {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	crc := crc32.ChecksumIEEE(data)
+	hdr := make([]byte, LocalHdrSize)
+	copy(hdr, ZIP_LocalHdrSig)
+	le.PutUint16(hdr[4:], 10)
+	le.PutUint16(hdr[12:], 0x4021) // 2012-01-01
+	le.PutUint32(hdr[14:], crc)
+	le.PutUint32(hdr[18:], uint32(len(data)))
+	le.PutUint32(hdr[22:], uint32(len(data)))
+	le.PutUint16(hdr[26:], uint16(len(name)))
+	b.Write(hdr)
+	b.WriteString(name)
+	b.Write(data)
+	dirOffset := b.Len()
+	dir := make([]byte, CentDirHdrSize)
+	copy(dir, ZIP_CentDirSig)
+	le.PutUint16(dir[4:], 10)
+	le.PutUint16(dir[6:], 10)
+	le.PutUint16(dir[14:], 0x4021)
+	le.PutUint32(dir[16:], crc)
+	le.PutUint32(dir[20:], uint32(len(data)))
+	le.PutUint32(dir[24:], uint32(len(data)))
+	le.PutUint16(dir[28:], uint16(len(name)))
+	b.Write(dir)
+	b.WriteString(name)
+	end := make([]byte, EndCentDirSize)
+	copy(end, ZIP_EndCentDirSig)
+	le.PutUint16(end[8:], 1)
+	le.PutUint16(end[10:], 1)
+	le.PutUint32(end[12:], uint32(b.Len()-dirOffset))
+	le.PutUint32(end[16:], uint32(dirOffset))
+	b.Write(end)
+	return bytes.NewReader(b.Bytes())
+}
@@ -0,0 +1,291 @@
+// manifest.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Manifests of what an archive holds, a checksum of every entry's contents
+// as it would be extracted.  CRC32 catches accidents but not tampering, so
+// release archives want something stronger.  Manifests are written the way
+// sha256sum writes them, so "sha256sum -c" works on the extracted tree, or
+// as JSON, and either can be read back to check an archive against.
+
+package zipfile
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+)
+
+var (
+	ManifestHashError   = errors.New("unknown manifest hash, want md5, sha1, sha256 or sha512")
+	ManifestFormatError = errors.New("manifest is neither JSON nor checksum lines")
+)
+
+// manifestHashes are the hashes a manifest can have, sha256sum and friends
+// tell them apart by length
+var manifestHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// ManifestEntry is one file in the archive.  Only regular files are listed,
+// a symlink's checksum would be of its target's name in the archive but of the
+// target's contents once extracted.
+type ManifestEntry struct {
+	Name string            `json:"name"`
+	Size int64             `json:"size"` // -1 if read from checksum lines, which don't say
+	Sums map[string]string `json:"sums"` // hex digests by hash name, "sha256" etc
+}
+
+// Manifest lists the entries of an archive with their checksums, in archive
+// order
+type Manifest struct {
+	Hashes  []string        `json:"hashes"`
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// ManifestProblem is an entry that doesn't match its manifest
+type ManifestProblem struct {
+	Name    string
+	Problem string // "missing", "not in manifest", "size", "no sum", "sha256" etc or a read error
+}
+
+// Manifest reads every file in the archive, computing the named hashes of
+// its contents as it goes.  With no hashes it uses sha256.
+func (r *ZipReader) Manifest(hashes ...string) (*Manifest, error) {
+	if len(hashes) == 0 {
+		hashes = []string{"sha256"}
+	}
+	for _, name := range hashes {
+		if manifestHashes[name] == nil {
+			return nil, ManifestHashError
+		}
+	}
+	filelist, err := r.CentralHeaders()
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{Hashes: hashes}
+	for _, h := range filelist {
+		if !h.Mode().IsRegular() {
+			continue
+		}
+		size, sums, err := hashEntry(h, hashes)
+		if err != nil {
+			return m, err
+		}
+		m.Entries = append(m.Entries, ManifestEntry{Name: h.Name, Size: size, Sums: sums})
+	}
+	return m, nil
+}
+
+// hashEntry runs an entry through the hashes.  Open() has already expanded
+// the whole entry into memory, so it costs its size however it is hashed.
+func hashEntry(h *Header, hashes []string) (int64, map[string]string, error) {
+	rdr, err := h.Open()
+	if err != nil {
+		return 0, nil, err
+	}
+	hs := make([]hash.Hash, len(hashes))
+	ws := make([]io.Writer, len(hashes))
+	for ndx, name := range hashes {
+		hs[ndx] = manifestHashes[name]()
+		ws[ndx] = hs[ndx]
+	}
+	size, err := io.Copy(io.MultiWriter(ws...), rdr)
+	if err != nil {
+		return size, nil, err
+	}
+	sums := make(map[string]string, len(hashes))
+	for ndx, name := range hashes {
+		sums[name] = hex.EncodeToString(hs[ndx].Sum(nil))
+	}
+	return size, sums, nil
+}
+
+// WriteSums writes the manifest as sha256sum (or md5sum, ...) would, one
+// "digest  name" line per entry.  Names with a newline or backslash are
+// escaped and the line starts with a backslash, as coreutils does.
+func (m *Manifest) WriteSums(w io.Writer, hash string) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range m.Entries {
+		sum, ok := e.Sums[hash]
+		if !ok {
+			return ManifestHashError
+		}
+		name := e.Name
+		if strings.ContainsAny(name, "\\\n") {
+			name = strings.Replace(name, "\\", "\\\\", -1)
+			name = strings.Replace(name, "\n", "\\n", -1)
+			bw.WriteString("\\")
+		}
+		bw.WriteString(sum + "  " + name + "\n")
+	}
+	return bw.Flush()
+}
+
+// WriteJSON writes the manifest as indented JSON
+func (m *Manifest) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ReadManifest reads a manifest written by WriteJSON or WriteSums, or by
+// sha256sum and friends.  Which hash a checksum line has is told from its
+// length.
+func ReadManifest(rdr io.Reader) (*Manifest, error) {
+	data, err := readAllLimited(rdr)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		m := new(Manifest)
+		if err := json.Unmarshal(trimmed, m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	m := new(Manifest)
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+		space := strings.IndexByte(line, ' ')
+		if space < 0 || space+2 > len(line) || (line[space+1] != ' ' && line[space+1] != '*') {
+			return nil, ManifestFormatError
+		}
+		sum, name := strings.ToLower(line[:space]), line[space+2:]
+		if _, err := hex.DecodeString(sum); err != nil {
+			return nil, ManifestFormatError
+		}
+		var hashName string
+		switch len(sum) {
+		case 2 * md5.Size:
+			hashName = "md5"
+		case 2 * sha1.Size:
+			hashName = "sha1"
+		case 2 * sha256.Size:
+			hashName = "sha256"
+		case 2 * sha512.Size:
+			hashName = "sha512"
+		default:
+			return nil, ManifestHashError
+		}
+		if escaped {
+			name = strings.NewReplacer("\\\\", "\\", "\\n", "\n").Replace(name)
+		}
+		if !seen[hashName] {
+			seen[hashName] = true
+			m.Hashes = append(m.Hashes, hashName)
+		}
+		m.Entries = append(m.Entries, ManifestEntry{Name: name, Size: -1, Sums: map[string]string{hashName: sum}})
+	}
+	if len(m.Entries) == 0 {
+		return nil, ManifestFormatError
+	}
+	return m, nil
+}
+
+// MaxManifest is the biggest manifest ReadManifest will read
+const MaxManifest = 64 << 20
+
+// readAllLimited reads a manifest, which has no business being bigger than
+// MaxManifest
+func readAllLimited(rdr io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(rdr, MaxManifest+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > MaxManifest {
+		return nil, ManifestFormatError
+	}
+	return buf.Bytes(), nil
+}
+
+// VerifyManifest checks the archive against a manifest, reading every entry
+// the manifest names.  It returns the entries that don't match, none if the
+// archive is as the manifest says, and an error only if the archive can't be
+// read at all.  Names listed twice are matched up in order.  An entry with no
+// sum under any of the manifest's hashes is a problem, not a match.
+func (r *ZipReader) VerifyManifest(m *Manifest) ([]ManifestProblem, error) {
+	filelist, err := r.CentralHeaders()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string][]*Header)
+	for _, h := range filelist {
+		if h.Mode().IsRegular() {
+			byName[h.Name] = append(byName[h.Name], h)
+		}
+	}
+	var problems []ManifestProblem
+	for _, e := range m.Entries {
+		hdrs := byName[e.Name]
+		if len(hdrs) == 0 {
+			problems = append(problems, ManifestProblem{e.Name, "missing"})
+			continue
+		}
+		h := hdrs[0]
+		byName[e.Name] = hdrs[1:]
+		if e.Size >= 0 && h.Size != e.Size {
+			problems = append(problems, ManifestProblem{e.Name, "size"})
+			continue
+		}
+		var hashes []string
+		for _, name := range m.Hashes {
+			if _, ok := e.Sums[name]; ok {
+				if manifestHashes[name] == nil {
+					return problems, ManifestHashError
+				}
+				hashes = append(hashes, name)
+			}
+		}
+		if len(hashes) == 0 {
+			problems = append(problems, ManifestProblem{e.Name, "no sum"})
+			continue
+		}
+		_, sums, err := hashEntry(h, hashes)
+		if err != nil {
+			problems = append(problems, ManifestProblem{e.Name, err.Error()})
+			continue
+		}
+		for _, name := range hashes {
+			if !strings.EqualFold(sums[name], e.Sums[name]) {
+				problems = append(problems, ManifestProblem{e.Name, name})
+				break
+			}
+		}
+	}
+	for _, h := range filelist {
+		if hdrs := byName[h.Name]; len(hdrs) > 0 && hdrs[0] == h {
+			problems = append(problems, ManifestProblem{h.Name, "not in manifest"})
+			byName[h.Name] = hdrs[1:]
+		}
+	}
+	return problems, nil
+}
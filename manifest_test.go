@@ -0,0 +1,111 @@
+// manifest_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// what sha256sum says of unix.zip once extracted
+const unixSums = "a901e631ccd6e5dec764aa6b8d5ccc63a3be2063de74dedfc5a9037637add4b6  unix/readme.txt\n" +
+	"bfdeaeb08cffb6a36438bcd12dda25417e3cdd36f1e7e482a2849d539225288b  unix/bin/hello.sh\n"
+
+// Purpose: manifests match sha256sum, survive both formats and catch changes
+func TestManifest(t *testing.T) {
+	fmt.Printf("TestManifest start\n")
+	f, err := os.Open("testdata/unix.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	m, err := rz.Manifest("sha256", "md5")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := m.WriteSums(&buf, "sha256"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if buf.String() != unixSums {
+		t.Errorf("got\n%s\nexpected\n%s", buf.String(), unixSums)
+	}
+	if err := m.WriteSums(&buf, "sha1"); err != ManifestHashError {
+		t.Errorf("expected ManifestHashError for a hash not computed, got %v", err)
+	}
+
+	buf.Reset()
+	if err := m.WriteJSON(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fromJSON, err := ReadManifest(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(fromJSON, m) {
+		t.Errorf("JSON round trip got %v, expected %v", fromJSON, m)
+	}
+	fromSums, err := ReadManifest(strings.NewReader(unixSums))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, good := range []*Manifest{m, fromJSON, fromSums} {
+		if problems, err := rz.VerifyManifest(good); err != nil || len(problems) != 0 {
+			t.Errorf("archive doesn't match its own manifest: %v, %v", problems, err)
+		}
+	}
+
+	// readme.txt changed, hello.sh not listed and gone.txt not there
+	bad, err := ReadManifest(strings.NewReader(strings.Replace(strings.SplitAfter(unixSums, "\n")[0], "a901", "a902", 1) +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855 *unix/gone.txt\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	problems, err := rz.VerifyManifest(bad)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []ManifestProblem{
+		{"unix/readme.txt", "sha256"},
+		{"unix/gone.txt", "missing"},
+		{"unix/bin/hello.sh", "not in manifest"},
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("got %v, expected %v", problems, want)
+	}
+
+	// entries whose sums can't be checked don't pass
+	unsummed := &Manifest{Hashes: []string{"sha256"}}
+	for _, e := range m.Entries {
+		e.Sums = nil
+		if e.Name == "unix/bin/hello.sh" {
+			e.Sums = map[string]string{"md5": m.Entries[0].Sums["md5"]} // not a manifest hash
+		}
+		unsummed.Entries = append(unsummed.Entries, e)
+	}
+	problems, err = rz.VerifyManifest(unsummed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want = []ManifestProblem{{"unix/readme.txt", "no sum"}, {"unix/bin/hello.sh", "no sum"}}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("got %v, expected %v", problems, want)
+	}
+	if _, err := ReadManifest(strings.NewReader("not a manifest\n")); err != ManifestFormatError {
+		t.Errorf("expected ManifestFormatError, got %v", err)
+	}
+	fmt.Printf("TestManifest fini\n")
+}
@@ -0,0 +1,134 @@
+// merge.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Merging several archives into one, plugin bundles and the like.  Entry
+// data is copied raw (see OpenRaw), so merging costs I/O and no CPU.  Names
+// are decided for every entry before anything is written, so a merge that
+// fails on a conflict hasn't half written the output.
+
+package zipfile
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// MergePolicy decides what happens when archives being merged have entries
+// with the same name.  Directory entries are never a conflict, the first is
+// kept and the rest dropped whatever the policy.
+type MergePolicy int
+
+const (
+	MergeFirstWins MergePolicy = iota // keep the entry from the earliest archive
+	MergeLastWins                     // keep the entry from the latest archive
+	MergeError                        // fail with MergeConflictError
+	MergeRename                       // keep them all, later ones renamed
+)
+
+var MergeConflictError = errors.New("archives being merged have entries with the same name")
+
+// MergeOptions configure Merge, nil means MergeFirstWins
+type MergeOptions struct {
+	Policy MergePolicy
+	// Rename gives the new name for the nth (counting from 1) extra entry
+	// called name, for MergeRename.  nil puts "~n" in front of the extension,
+	// "lib/util.js" becomes "lib/util~1.js".  Names already taken are
+	// skipped by trying the next n.
+	Rename func(name string, n int) string
+	// Filter leaves out entries it returns false for, before any question of
+	// duplicates comes up.  nil keeps them all, see Selector.Match.
+	Filter func(*Header) bool
+}
+
+// Merged says what Merge did with one entry of one source archive
+type Merged struct {
+	Source  int    // index of the archive in sources
+	Orig    string // name in that archive
+	Name    string // name in the merged archive, empty if Skipped
+	Skipped bool   // a duplicate the policy left out, or a repeated directory
+}
+
+// Merge copies the entries of sources, in order, to w.  It reports what
+// became of every source entry Filter lets through, also when it fails with MergeConflictError,
+// so the caller can say which names clashed.  w is not closed.
+func Merge(w *Writer, sources []*ZipReader, opts *MergeOptions) ([]Merged, error) {
+	if opts == nil {
+		opts = &MergeOptions{}
+	}
+	rename := opts.Rename
+	if rename == nil {
+		rename = mergeRename
+	}
+	var hdrs []*Header
+	var report []Merged
+	byName := make(map[string]int) // index in report of the entry holding a name
+	taken := make(map[string]bool) // every name in use, renames included
+	var conflict error
+	for src, rz := range sources {
+		filelist, err := rz.CentralHeaders()
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range filelist {
+			if opts.Filter != nil && !opts.Filter(h) {
+				continue
+			}
+			m := Merged{Source: src, Orig: h.Name, Name: h.Name}
+			prev, dup := byName[h.Name]
+			switch {
+			case !dup && !taken[h.Name]: // taken without dup is a name MergeRename made up
+				byName[h.Name] = len(report)
+			case h.Typeflag == TypeDir || opts.Policy == MergeFirstWins:
+				m.Name, m.Skipped = "", true
+			case opts.Policy == MergeLastWins:
+				report[prev].Name, report[prev].Skipped = "", true
+				byName[h.Name] = len(report)
+			case opts.Policy == MergeError:
+				conflict = MergeConflictError
+				m.Name, m.Skipped = "", true
+			case opts.Policy == MergeRename:
+				for n := 1; ; n++ {
+					if m.Name = rename(h.Name, n); !taken[m.Name] {
+						break
+					}
+				}
+			default:
+				return nil, fmt.Errorf("unknown MergePolicy %d", opts.Policy)
+			}
+			if !m.Skipped {
+				taken[m.Name] = true
+			}
+			hdrs = append(hdrs, h)
+			report = append(report, m)
+		}
+	}
+	if conflict != nil {
+		return report, conflict
+	}
+	for ndx, m := range report {
+		if m.Skipped {
+			continue
+		}
+		nh := rawHeader(hdrs[ndx])
+		nh.Name = m.Name
+		if err := copyRaw(w, hdrs[ndx], nh); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// mergeRename is the default MergeOptions.Rename
+func mergeRename(name string, n int) string {
+	ext := path.Ext(name)
+	if ext == path.Base(name) {
+		ext = "" // ".profile" is all name
+	}
+	return fmt.Sprintf("%s~%d%s", strings.TrimSuffix(name, ext), n, ext)
+}
@@ -0,0 +1,133 @@
+// merge_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// mergeSources are the archives TestMerge combines, name to contents
+var mergeSources = [][][2]string{
+	{{"dir/", ""}, {"dir/a.txt", "a from 0"}, {"b.txt", "b from 0"}},
+	{{"dir/", ""}, {"dir/a.txt", "a from 1"}, {"c.txt", "c from 1"}},
+	{{"dir/a~1.txt", "made up name from 2"}},
+}
+
+func mergeReaders(t *testing.T) []*ZipReader {
+	var readers []*ZipReader
+	for _, entries := range mergeSources {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		for _, e := range entries {
+			fw, err := w.Create(&Header{Name: e[0], Compress: ZIP_DEFLATED})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			io.WriteString(fw, e[1])
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		rz, err := NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		readers = append(readers, rz)
+	}
+	return readers
+}
+
+// Purpose: each policy gives the expected names and contents, directories
+// are only written once
+func TestMerge(t *testing.T) {
+	fmt.Printf("TestMerge start\n")
+	tests := []struct {
+		policy MergePolicy
+		want   map[string]string
+	}{
+		{MergeFirstWins, map[string]string{"dir/": "", "dir/a.txt": "a from 0", "b.txt": "b from 0",
+			"c.txt": "c from 1", "dir/a~1.txt": "made up name from 2"}},
+		{MergeLastWins, map[string]string{"dir/": "", "dir/a.txt": "a from 1", "b.txt": "b from 0",
+			"c.txt": "c from 1", "dir/a~1.txt": "made up name from 2"}},
+		{MergeRename, map[string]string{"dir/": "", "dir/a.txt": "a from 0", "b.txt": "b from 0",
+			"dir/a~1.txt": "a from 1", "c.txt": "c from 1", "dir/a~1~1.txt": "made up name from 2"}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		report, err := Merge(w, mergeReaders(t), &MergeOptions{Policy: tt.policy})
+		if err != nil {
+			t.Fatalf("policy %d: Unexpected error: %v", tt.policy, err)
+		}
+		if err = w.Close(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(report) != 7 {
+			t.Errorf("policy %d: report has %d entries", tt.policy, len(report))
+		}
+		_, hdrs := centralByName(t, buf.Bytes())
+		if len(hdrs) != len(tt.want) {
+			t.Errorf("policy %d: got %d entries, expected %d", tt.policy, len(hdrs), len(tt.want))
+		}
+		for name, data := range tt.want {
+			h := hdrs[name]
+			if h == nil {
+				t.Errorf("policy %d: %s missing", tt.policy, name)
+				continue
+			}
+			rdr, err := h.Open()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got, _ := ioutil.ReadAll(rdr); string(got) != data {
+				t.Errorf("policy %d: %s reads %q, expected %q", tt.policy, name, got, data)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	report, err := Merge(NewWriter(&buf), mergeReaders(t), &MergeOptions{Policy: MergeError})
+	if err != MergeConflictError {
+		t.Fatalf("expected MergeConflictError, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("failed merge wrote %d bytes", buf.Len())
+	}
+	clashes := 0
+	for _, m := range report {
+		if m.Skipped && m.Orig == "dir/a.txt" && m.Source == 1 {
+			clashes++
+		}
+	}
+	if clashes != 1 {
+		t.Errorf("conflict not reported: %+v", report)
+	}
+
+	// with the clashing name filtered out there's no conflict
+	g, err := Glob("dir/a.txt")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	buf.Reset()
+	w := NewWriter(&buf)
+	sel := &Selector{Exclude: []Pattern{g}}
+	if report, err = Merge(w, mergeReaders(t), &MergeOptions{Policy: MergeError, Filter: sel.Match}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, hdrs := centralByName(t, buf.Bytes()); len(hdrs) != 4 || hdrs["dir/a.txt"] != nil || len(report) != 5 {
+		t.Errorf("filtered merge has %d entries, report %+v", len(hdrs), report)
+	}
+	fmt.Printf("TestMerge fini\n")
+}
@@ -0,0 +1,148 @@
+// nested.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Archives inside archives, jars in wars in zips.  Walk visits every entry
+// of an archive and of every archive inside it, however it was named, since
+// what counts is the signature at the front of the entry's data.  Paths are
+// written the way Java tools write them, outer.zip!/lib/inner.jar!/a/b.txt.
+
+package zipfile
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	SkipNested        = errors.New("skip this nested archive") // not an error, see WalkFunc
+	NegativeSeekError = errors.New("seek to before the start of a nested archive")
+)
+
+// MaxWalkDepth is how deep Walk goes when the reader's Limits.MaxNesting is
+// zero, an archive that contains itself would otherwise go on forever
+const MaxWalkDepth = 16
+
+// WalkFunc is called by Walk for each entry.  path is the entry's name after
+// those of the archives holding it.  err is nil except for an entry that
+// looks like an archive but can't be opened as one, corrupt, encrypted or too
+// deep, when fn is called a second time with the reason.  Returning SkipNested
+// from the first call doesn't look inside the entry, returning any other
+// error stops the walk with that error.
+type WalkFunc func(path string, h *Header, err error) error
+
+// Walk calls fn for every entry of the archive, in directory order, and
+// descends into every entry that is itself a zip archive.  name starts every
+// path, usually the archive's file name.
+func (r *ZipReader) Walk(name string, fn WalkFunc) error {
+	filelist, err := r.CentralHeaders()
+	if err != nil {
+		return err
+	}
+	maxDepth := r.limits.MaxNesting
+	if maxDepth == 0 {
+		maxDepth = MaxWalkDepth
+	}
+	for _, h := range filelist {
+		p := name + "!/" + h.Name
+		err := fn(p, h, nil)
+		if err == SkipNested || (err == nil && !h.looksLikeZip()) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var nz *ZipReader
+		if r.depth+1 > maxDepth {
+			err = &LimitError{"MaxNesting", h.Name}
+		} else {
+			nz, err = r.OpenNested(h)
+		}
+		if err != nil {
+			if err = fn(p, h, err); err != nil && err != SkipNested {
+				return err
+			}
+			continue
+		}
+		if err = nz.Walk(p, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// looksLikeZip peeks at the start of the entry's data for a zip signature,
+// decompressing no more than it has to.  Encrypted entries can't be peeked
+// at and are taken to be something else.
+func (h *Header) looksLikeZip() bool {
+	if h.Typeflag != TypeReg || h.Size < EndCentDirSize || h.Encrypted() {
+		return false
+	}
+	rdr, err := h.OpenRaw()
+	if err != nil {
+		return false
+	}
+	if h.Compress != ZIP_STORED {
+		dcomp := decompressor(h.Compress)
+		if dcomp == nil {
+			return false
+		}
+		rc := dcomp(rdr)
+		defer rc.Close()
+		rdr = rc
+	}
+	sig := make([]byte, 4)
+	if _, err := io.ReadFull(rdr, sig); err != nil {
+		return false
+	}
+	return string(sig) == ZIP_LocalHdrSig || string(sig) == ZIP_EndCentDirSig
+}
+
+// newSection returns a reader for n bytes of rs from off, sharing rs with
+// whoever else is using it
+func newSection(rs io.ReadSeeker, off, n int64) io.ReadSeeker {
+	if ra, ok := rs.(io.ReaderAt); ok {
+		return io.NewSectionReader(ra, off, n)
+	}
+	return &sectionSeeker{rs: rs, base: off, size: n}
+}
+
+// sectionSeeker is io.SectionReader for readers without ReadAt.  It seeks
+// before every read, as Open does, so sharing rs does no harm.
+type sectionSeeker struct {
+	rs         io.ReadSeeker
+	base, size int64
+	pos        int64
+}
+
+func (s *sectionSeeker) Read(p []byte) (int, error) {
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+	if _, err := s.rs.Seek(s.base+s.pos, 0); err != nil {
+		return 0, err
+	}
+	if max := s.size - s.pos; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := s.rs.Read(p)
+	s.pos += int64(n)
+	return n, err
+}
+
+func (s *sectionSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case 1:
+		offset += s.pos
+	case 2:
+		offset += s.size
+	}
+	if offset < 0 {
+		return s.pos, NegativeSeekError
+	}
+	s.pos = offset
+	return offset, nil
+}
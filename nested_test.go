@@ -0,0 +1,151 @@
+// nested_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// nestedZip makes an archive of name, method, contents triples
+func nestedZip(t *testing.T, entries ...interface{}) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := 0; i < len(entries); i += 3 {
+		h := &Header{Name: entries[i].(string), Compress: uint16(entries[i+1].(int))}
+		fw, err := w.Create(h)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		fw.Write(entries[i+2].([]byte))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// seekOnly hides ReadAt, so nested archives get a sectionSeeker
+type seekOnly struct {
+	io.ReadSeeker
+}
+
+// Purpose: Walk finds archives by signature, stored or deflated, and stops
+// where it is told to
+func TestWalk(t *testing.T) {
+	fmt.Printf("TestWalk start\n")
+	deep := nestedZip(t, "a.txt", ZIP_DEFLATED, []byte("aaaa"))
+	inner := nestedZip(t,
+		"META-INF/MANIFEST.MF", ZIP_DEFLATED, []byte("Manifest-Version: 1.0\n"),
+		"deep.bin", ZIP_DEFLATED, deep)
+	outer := nestedZip(t,
+		"lib/inner.jar", ZIP_STORED, inner,
+		"readme.zip", ZIP_DEFLATED, []byte("not really a zip, whatever its name says"))
+	all := []string{
+		"outer.zip!/lib/inner.jar",
+		"outer.zip!/lib/inner.jar!/META-INF/MANIFEST.MF",
+		"outer.zip!/lib/inner.jar!/deep.bin",
+		"outer.zip!/lib/inner.jar!/deep.bin!/a.txt",
+		"outer.zip!/readme.zip",
+	}
+	tests := []struct {
+		limits Limits
+		skip   string // path to return SkipNested for
+		want   []string
+		errs   []string // paths fn was given an error for
+	}{
+		{Limits{}, "", all, nil},
+		{Limits{MaxNesting: 1}, "", append(all[:3:3], all[4]), []string{"outer.zip!/lib/inner.jar!/deep.bin"}},
+		{Limits{}, "outer.zip!/lib/inner.jar", []string{all[0], all[4]}, nil},
+	}
+	for ndx, tt := range tests {
+		for _, rs := range []io.ReadSeeker{bytes.NewReader(outer), seekOnly{bytes.NewReader(outer)}} {
+			rz, err := NewReader(rs)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			rz.SetLimits(tt.limits)
+			var got, errs []string
+			err = rz.Walk("outer.zip", func(path string, h *Header, err error) error {
+				if err != nil {
+					if !limitHit(err, "MaxNesting") {
+						t.Errorf("test %d: %s: unexpected error %v", ndx, path, err)
+					}
+					errs = append(errs, path)
+					return nil
+				}
+				got = append(got, path)
+				if h.Name == "META-INF/MANIFEST.MF" {
+					rdr, err := h.Open()
+					if err != nil {
+						t.Fatalf("Unexpected error: %v", err)
+					}
+					var data bytes.Buffer
+					io.Copy(&data, rdr)
+					if data.String() != "Manifest-Version: 1.0\n" {
+						t.Errorf("test %d: read %q from the stored jar", ndx, data.String())
+					}
+				}
+				if path == tt.skip {
+					return SkipNested
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(errs, tt.errs) {
+				t.Errorf("test %d: got %v and errors for %v, expected %v and %v", ndx, got, errs, tt.want, tt.errs)
+			}
+		}
+	}
+	fmt.Printf("TestWalk fini\n")
+}
+
+// Purpose: encrypted entries of a nested archive open with the outer
+// reader's password
+func TestNestedPassword(t *testing.T) {
+	fmt.Printf("TestNestedPassword start\n")
+	inner, err := ioutil.ReadFile("testdata/crypt.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	archive := nestedZip(t, "lib/crypt.zip", ZIP_DEFLATED, inner)
+	rz, err := NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rz.SetPassword("swordfish")
+	var got string
+	err = rz.Walk("", func(p string, h *Header, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != "!/lib/crypt.zip!/secret.txt" {
+			return nil
+		}
+		rdr, err := h.Open()
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(rdr)
+		got = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != "secret contents, not for everyone\n" {
+		t.Errorf("secret.txt reads %q", got)
+	}
+	fmt.Printf("TestNestedPassword fini\n")
+}
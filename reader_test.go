@@ -19,6 +19,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 // Purpose: exercise NewReader(),Next(), Dump() on a valid zip file
@@ -280,6 +281,81 @@ func TestConcurrent(t *testing.T) {
 	fmt.Printf("TestConcurrent finishing normally\n")
 }
 
+// Purpose: out of range MSDOS dates are caught rather than rolled over
+func TestCheckDate(t *testing.T) {
+	fmt.Printf("TestCheckDate start\n")
+	dos := func(y, mo, d, h, mi, s int) (uint16, uint16) {
+		return uint16((y-MSDOS_EPOCH)<<9 | mo<<5 | d), uint16(h<<11 | mi<<5 | s/2)
+	}
+	cases := []struct {
+		y, mo, d, h, mi, s int
+		ok                 bool
+	}{
+		{2012, 2, 29, 23, 59, 58, true},
+		{2013, 2, 29, 12, 0, 0, false},
+		{2012, 4, 31, 12, 0, 0, false},
+		{2012, 13, 1, 12, 0, 0, false},
+		{2012, 0, 1, 12, 0, 0, false},
+		{2012, 1, 0, 12, 0, 0, false},
+		{2012, 1, 1, 24, 0, 0, false},
+		{2012, 1, 1, 12, 60, 0, false},
+		{2012, 1, 1, 12, 0, 60, false},
+	}
+	for _, c := range cases {
+		d, tm := dos(c.y, c.mo, c.d, c.h, c.mi, c.s)
+		if dosDateOK(d, tm) != c.ok {
+			t.Errorf("%+v: expected ok %v", c, c.ok)
+		}
+	}
+	h := new(Header)
+	if err := h.CheckDate(); err != BadDateError {
+		t.Errorf("zero date: expected BadDateError, got %v", err)
+	}
+	h.dosDate, h.dosTime = dos(2107, 12, 31, 0, 0, 0)
+	h.Mtime = makeGoDate(h.dosDate, h.dosTime)
+	if err := h.CheckDate(); err != FutureTimeError {
+		t.Errorf("2107: expected FutureTimeError, got %v", err)
+	}
+	f, err := os.Open("testdata/unix.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, hdr := range filelist {
+		if err := hdr.CheckDate(); err != nil {
+			t.Errorf("%s: Unexpected error: %v", hdr.Name, err)
+		}
+	}
+	fmt.Printf("TestCheckDate fini\n")
+}
+
+// Purpose: every minute decodes, the minute field is six bits and minutes
+// 16 and up were once read with only four of them
+func TestMakeGoDate(t *testing.T) {
+	fmt.Printf("TestMakeGoDate start\n")
+	d := uint16((2012-MSDOS_EPOCH)<<9 | 3<<5 | 4)
+	for mi := 0; mi < 60; mi++ {
+		tm := uint16(13<<11 | mi<<5 | 21)
+		got := makeGoDate(d, tm)
+		want := time.Date(2012, 3, 4, 13, mi, 42, 0, time.UTC)
+		if !got.Equal(want) {
+			t.Errorf("minute %d: got %v, expected %v", mi, got, want)
+		}
+		if gd, gt := makeDosDate(got); gd != d || gt != tm {
+			t.Errorf("minute %d: round trip gave %04x %04x, expected %04x %04x", mi, gd, gt, d, tm)
+		}
+	}
+	fmt.Printf("TestMakeGoDate fini\n")
+}
+
 /* // Test template
 func TestXXX (t *testing.T) {
     if false {
@@ -0,0 +1,140 @@
+// select.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Choosing entries by name, size and date, so that listing, extracting and
+// copying all pick the same ones the same way.  A Selector's Match method
+// fits ExtractOptions.Filter and MergeOptions.Filter as it is.
+
+package zipfile
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// Pattern matches entry names.  *regexp.Regexp is one, Glob makes others.
+type Pattern interface {
+	MatchString(name string) bool
+}
+
+// glob is a Pattern made by Glob
+type glob struct {
+	pattern string
+	segs    []string // pattern split at slashes
+	base    bool     // no slash in pattern, try it on the last element too
+}
+
+// Glob compiles a path.Match pattern, matched one slash separated element at
+// a time, with "**" as a whole element matching any number of elements, none
+// included.  A pattern without a slash also matches the last element of a
+// name, as zip -x does, so "*.txt" matches "a/b.txt".  Directory names are
+// matched without their trailing slash.
+func Glob(pattern string) (Pattern, error) {
+	var segs []string
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg == "**" {
+			if len(segs) > 0 && segs[len(segs)-1] == "**" {
+				continue // a/**/**/b is a/**/b, and much slower
+			}
+		} else if _, err := path.Match(seg, ""); err != nil {
+			return nil, err
+		}
+		segs = append(segs, seg)
+	}
+	return &glob{pattern: pattern, segs: segs, base: !strings.Contains(pattern, "/")}, nil
+}
+
+func (g *glob) MatchString(name string) bool {
+	parts := strings.Split(strings.TrimSuffix(name, "/"), "/")
+	if g.base && matchSegs(g.segs, parts[len(parts)-1:]) {
+		return true
+	}
+	return matchSegs(g.segs, parts)
+}
+
+func (g *glob) String() string {
+	return g.pattern
+}
+
+// matchSegs matches name elements against pattern elements
+func matchSegs(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegs(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// Selector picks entries.  An entry is selected if every condition set
+// holds, a nil or zero Selector selects everything.
+type Selector struct {
+	Include []Pattern // name matches one of these, any name if empty
+	Exclude []Pattern // name matches none of these
+	MinSize int64     // uncompressed size at least this
+	MaxSize int64     // uncompressed size at most this, 0 means no limit
+	After   time.Time // ModTime() after this, zero means no limit
+	Before  time.Time // ModTime() before this, zero means no limit
+}
+
+// Match is true if s selects h
+func (s *Selector) Match(h *Header) bool {
+	if s == nil {
+		return true
+	}
+	for _, p := range s.Exclude {
+		if p.MatchString(h.Name) {
+			return false
+		}
+	}
+	if h.Size < s.MinSize || (s.MaxSize > 0 && h.Size > s.MaxSize) {
+		return false
+	}
+	if !s.After.IsZero() && !h.ModTime().After(s.After) {
+		return false
+	}
+	if !s.Before.IsZero() && !h.ModTime().Before(s.Before) {
+		return false
+	}
+	if len(s.Include) == 0 {
+		return true
+	}
+	for _, p := range s.Include {
+		if p.MatchString(h.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the entries of the central directory that s selects
+func (r *ZipReader) Select(s *Selector) ([]*Header, error) {
+	filelist, err := r.CentralHeaders()
+	if err != nil {
+		return nil, err
+	}
+	var picked []*Header
+	for _, h := range filelist {
+		if s.Match(h) {
+			picked = append(picked, h)
+		}
+	}
+	return picked, nil
+}
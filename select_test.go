@@ -0,0 +1,106 @@
+// select_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// Purpose: globs match by element, ** spans directories and slashless
+// patterns match base names
+func TestGlob(t *testing.T) {
+	fmt.Printf("TestGlob start\n")
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.txt", "a.txt", true},
+		{"*.txt", "dir/a.txt", true},
+		{"*.txt", "dir/a.txt/", true},
+		{"dir/*.txt", "dir/a.txt", true},
+		{"dir/*.txt", "dir/sub/a.txt", false},
+		{"dir/*", "dir/sub/a.txt", false},
+		{"dir/**", "dir/sub/a.txt", true},
+		{"dir/**", "dir/", true},
+		{"dir/**/a.txt", "dir/a.txt", true},
+		{"dir/**/a.txt", "dir/x/y/a.txt", true},
+		{"dir/**/a.txt", "dir/x/y/b.txt", false},
+		{"**/META-INF/*.SF", "lib/x.jar/META-INF/X.SF", true},
+		{"**/**/a.txt", "a.txt", true},
+		{"a?c/[0-9]", "abc/7", true},
+		{"a?c/[0-9]", "abc/x", false},
+		{"sub/a.txt", "dir/sub/a.txt", false},
+	}
+	for _, tt := range tests {
+		g, err := Glob(tt.pattern)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := g.MatchString(tt.name); got != tt.want {
+			t.Errorf("Glob(%q).MatchString(%q) = %v, expected %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+	if _, err := Glob("dir/[a-"); err == nil {
+		t.Errorf("bad pattern accepted")
+	}
+	fmt.Printf("TestGlob fini\n")
+}
+
+// Purpose: each kind of Selector condition, alone and together
+func TestSelect(t *testing.T) {
+	fmt.Printf("TestSelect start\n")
+	f, err := os.Open("testdata/unix.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	mustGlob := func(pattern string) Pattern {
+		g, err := Glob(pattern)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return g
+	}
+	mtime := time.Date(2012, 1, 2, 3, 4, 0, 0, time.UTC) // every entry's, give or take a minute
+	tests := []struct {
+		sel  *Selector
+		want []string
+	}{
+		{nil, []string{"unix/", "unix/readme.txt", "unix/link.txt", "unix/bin/", "unix/bin/hello.sh"}},
+		{&Selector{Include: []Pattern{mustGlob("*.txt")}}, []string{"unix/readme.txt", "unix/link.txt"}},
+		{&Selector{Include: []Pattern{regexp.MustCompile(`\.sh$`), mustGlob("unix/bin")}}, []string{"unix/bin/", "unix/bin/hello.sh"}},
+		{&Selector{Exclude: []Pattern{mustGlob("unix/bin/**")}}, []string{"unix/", "unix/readme.txt", "unix/link.txt"}},
+		{&Selector{MinSize: 11, MaxSize: 20}, []string{"unix/readme.txt"}},
+		{&Selector{MinSize: 1, Exclude: []Pattern{mustGlob("link.txt")}}, []string{"unix/readme.txt", "unix/bin/hello.sh"}},
+		{&Selector{After: mtime.Add(-time.Minute), Before: mtime.Add(time.Minute), MaxSize: 15}, []string{"unix/", "unix/link.txt", "unix/bin/"}},
+		{&Selector{After: mtime.Add(time.Minute)}, nil},
+	}
+	for ndx, tt := range tests {
+		picked, err := rz.Select(tt.sel)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var got []string
+		for _, h := range picked {
+			got = append(got, h.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test %d: got %v, expected %v", ndx, got, tt.want)
+		}
+	}
+	fmt.Printf("TestSelect fini\n")
+}
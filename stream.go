@@ -0,0 +1,277 @@
+// stream.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// StreamReader reads an archive front to back from a plain io.Reader, so a
+// zip arriving on stdin or in an HTTP request body can be processed without
+// first saving it somewhere seekable.  It works like archive/tar: Next()
+// moves to the next entry and then the StreamReader itself is read to get
+// that entry's contents.
+
+package zipfile
+
+import (
+	"bufio"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	ZIP_DataDescSig = "PK\007\010"
+	ZIP_SpanningSig = ZIP_DataDescSig // same bytes start a split archive
+	ZIP_SpannedSig  = "PK00"          // written by some tools that planned to split but didn't
+	DataDescSize    = 12              // crc32, compressed size, uncompressed size
+
+	flagDataDesc = 0x0008 // sizes and crc follow the data instead of being in the local header
+)
+
+var (
+	StreamOpenError   = errors.New("entry came from a StreamReader, read its contents from there")
+	StreamStoredError = errors.New("stored entry with data descriptor can't be streamed, size is unknown")
+	StreamCryptError  = errors.New("encrypted entry can't be streamed")
+)
+
+// StreamReader gives sequential access to an archive that can't seek.
+// Headers returned by Next have no Hreader, so Open() won't work on them,
+// read the StreamReader instead.  An entry's sizes and CRC may not be known
+// until its data has been read (data descriptor, general purpose bit 3),
+// they are filled into the Header once Read returns io.EOF.
+type StreamReader struct {
+	r        *countingReader
+	hdr      *Header // current entry
+	rdr      io.Reader
+	crc      hash.Hash32
+	n        int64 // bytes of the current entry's contents read so far
+	startPos int64 // where the current entry's compressed data started
+	eof      bool  // finished the current entry
+	err      error // sticky error, nothing more can be read
+	limits   Limits
+	expanded int64
+	entries  int
+}
+
+// NewStreamReader starts reading an archive from r.  Limits are copied from DefaultLimits.
+func NewStreamReader(r io.Reader) *StreamReader {
+	s := new(StreamReader)
+	s.r = &countingReader{r: bufio.NewReader(r)}
+	s.limits = DefaultLimits
+	return s
+}
+
+// SetLimits replaces the stream's limits, see limits.go
+func (s *StreamReader) SetLimits(l Limits) {
+	s.limits = l
+}
+
+// Next skips whatever is left of the current entry and returns the header of
+// the next one.  Returns nil, nil once the central directory is reached,
+// the same as ZipReader.Next().  An encrypted entry is returned along with
+// StreamCryptError, and if its sizes are in the local header it is skipped
+// so Next can go on to the entry after it.
+func (s *StreamReader) Next() (*Header, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.hdr != nil && !s.eof {
+		if _, err := io.Copy(ioutil.Discard, s); err != nil {
+			return nil, err
+		}
+	}
+	s.hdr = nil
+	localHdr := make([]byte, LocalHdrSize)
+	if _, err := io.ReadFull(s.r, localHdr[:4]); err != nil {
+		return nil, s.fail(err)
+	}
+	if s.r.n == 4 && (string(localHdr[:4]) == ZIP_SpanningSig || string(localHdr[:4]) == ZIP_SpannedSig) {
+		// marker at the very front of a split archive, the real header follows
+		if _, err := io.ReadFull(s.r, localHdr[:4]); err != nil {
+			return nil, s.fail(err)
+		}
+	}
+	if string(localHdr[:4]) == ZIP_CentDirSig || string(localHdr[:4]) == ZIP_EndCentDirSig {
+		s.err = io.EOF
+		return nil, nil
+	}
+	if _, err := io.ReadFull(s.r, localHdr[4:]); err != nil {
+		return nil, s.fail(err)
+	}
+	hdr := new(Header)
+	if err := hdr.unpackLocalHeader(localHdr); err != nil {
+		return nil, s.fail(err)
+	}
+	s.entries++
+	if s.limits.MaxEntries > 0 && s.entries > s.limits.MaxEntries {
+		return nil, s.fail(&LimitError{"MaxEntries", ""})
+	}
+	fname := make([]byte, sixteenBit(localHdr[26:28]))
+	if _, err := io.ReadFull(s.r, fname); err != nil {
+		return nil, s.fail(err)
+	}
+	hdr.Name = string(fname)
+	hdr.Typeflag = hdr.typeflag()
+	if _, err := io.CopyN(ioutil.Discard, s.r, int64(sixteenBit(localHdr[28:30]))); err != nil {
+		return nil, s.fail(err)
+	}
+	s.startPos = s.r.n
+	hdr.Offset = s.startPos
+	hdr.LocalOffset = s.startPos - int64(len(fname)) - int64(sixteenBit(localHdr[28:30])) - LocalHdrSize
+
+	descriptor := hdr.Flags&flagDataDesc != 0
+	if hdr.Encrypted() {
+		if descriptor {
+			return hdr, s.fail(StreamCryptError) // no telling where it ends
+		}
+		if _, err := io.CopyN(ioutil.Discard, s.r, hdr.SizeCompr); err != nil {
+			return nil, s.fail(err)
+		}
+		return hdr, StreamCryptError
+	}
+	if descriptor && hdr.Compress == ZIP_STORED {
+		return nil, s.fail(StreamStoredError)
+	}
+	src := &comprReader{r: s.r, left: -1}
+	if !descriptor {
+		src.left = hdr.SizeCompr
+	}
+	s.rdr = src
+	if dcomp := decompressor(hdr.Compress); dcomp != nil {
+		s.rdr = dcomp(src)
+	}
+	s.rdr = &limitReader{rdr: s.rdr, limits: &s.limits, expanded: &s.expanded,
+		name: hdr.Name, comprSize: &src.n}
+	s.hdr = hdr
+	s.crc = crc32.NewIEEE()
+	s.n = 0
+	s.eof = false
+	return hdr, nil
+}
+
+// Read reads the contents of the current entry.  The CRC is checked when the
+// end of the entry is reached, a mismatch is reported instead of io.EOF.
+func (s *StreamReader) Read(p []byte) (int, error) {
+	if s.hdr == nil || s.eof {
+		return 0, io.EOF
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	n, err := s.rdr.Read(p)
+	s.crc.Write(p[:n])
+	s.n += int64(n)
+	if err == io.EOF {
+		err = s.finishEntry()
+	} else if err != nil {
+		s.fail(err)
+	}
+	return n, err
+}
+
+// finishEntry reads the data descriptor if there is one and checks what we
+// got against what the headers said
+func (s *StreamReader) finishEntry() error {
+	hdr := s.hdr
+	if hdr.Compress != ZIP_STORED && hdr.Flags&flagDataDesc == 0 {
+		// anything the decompressor left inside SizeCompr is junk, skip it
+		if _, err := io.CopyN(ioutil.Discard, s.r, hdr.SizeCompr-(s.r.n-s.startPos)); err != nil {
+			return s.fail(err)
+		}
+	}
+	if hdr.Flags&flagDataDesc != 0 {
+		desc := make([]byte, DataDescSize)
+		if _, err := io.ReadFull(s.r, desc[:4]); err != nil {
+			return s.fail(err)
+		}
+		if string(desc[:4]) == ZIP_DataDescSig { // signature is optional
+			if _, err := io.ReadFull(s.r, desc[:4]); err != nil {
+				return s.fail(err)
+			}
+		}
+		if _, err := io.ReadFull(s.r, desc[4:]); err != nil {
+			return s.fail(err)
+		}
+		hdr.StoredCrc32 = thirtyTwoBit(desc[0:4])
+		hdr.SizeCompr = int64(thirtyTwoBit(desc[4:8]))
+		hdr.Size = int64(thirtyTwoBit(desc[8:12]))
+	}
+	s.eof = true
+	if s.n != hdr.Size {
+		return s.fail(ShortReadError)
+	}
+	if s.crc.Sum32() != hdr.StoredCrc32 {
+		return s.fail(CRC32MatchError)
+	}
+	return io.EOF
+}
+
+// fail records an error that leaves the stream position unknown
+func (s *StreamReader) fail(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	s.err = err
+	return err
+}
+
+// countingReader remembers how far into the archive we are
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// comprReader feeds an entry's compressed data to the decompressor, counting
+// it as it goes so MaxRatio is checked against what has really been read.
+// It is a ByteReader, so deflate finds its own end without reading past it.
+type comprReader struct {
+	r    *countingReader
+	left int64 // bytes left in the entry, -1 if unknown (data descriptor)
+	n    int64 // compressed bytes read so far
+}
+
+func (c *comprReader) Read(p []byte) (int, error) {
+	if c.left == 0 {
+		return 0, io.EOF
+	}
+	if c.left > 0 && int64(len(p)) > c.left {
+		p = p[:c.left]
+	}
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.left > 0 {
+		c.left -= int64(n)
+	}
+	return n, err
+}
+
+func (c *comprReader) ReadByte() (byte, error) {
+	if c.left == 0 {
+		return 0, io.EOF
+	}
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+		if c.left > 0 {
+			c.left--
+		}
+	}
+	return b, err
+}
@@ -0,0 +1,279 @@
+// stream_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// Purpose: StreamReader on a non-seekable reader should see the same entries
+// and contents as the seeking reader
+func TestStreamReader(t *testing.T) {
+	fmt.Printf("TestStreamReader start\n")
+	const testfile = "testdata/phpBB.zip"
+	f, err := os.Open(testfile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.Headers()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	g, err := os.Open(testfile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer g.Close()
+	sr := NewStreamReader(io.MultiReader(g)) // hides Seek
+	for ndx := 0; ; ndx++ {
+		hdr, err := sr.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if hdr == nil {
+			if ndx != len(filelist) {
+				t.Fatalf("stream saw %d entries, Headers() saw %d", ndx, len(filelist))
+			}
+			break
+		}
+		if hdr.Name != filelist[ndx].Name {
+			t.Fatalf("entry %d: stream %s, Headers() %s", ndx, hdr.Name, filelist[ndx].Name)
+		}
+		if ndx%3 == 0 {
+			continue // leave some for Next() to skip
+		}
+		got, err := ioutil.ReadAll(sr)
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", hdr.Name, err)
+		}
+		rdr, err := filelist[ndx].Open()
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", hdr.Name, err)
+		}
+		want, _ := ioutil.ReadAll(rdr)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: stream contents differ", hdr.Name)
+		}
+	}
+	fmt.Printf("TestStreamReader fini\n")
+}
+
+// Purpose: entries written to a pipe by Info-ZIP use data descriptors,
+// sizes and CRC arrive after the data
+func TestStreamDescriptor(t *testing.T) {
+	fmt.Printf("TestStreamDescriptor start\n")
+	f, err := os.Open("testdata/descr.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	sr := NewStreamReader(io.MultiReader(f))
+	for _, name := range []string{"stuf.txt", "mini.txt"} {
+		hdr, err := sr.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if hdr == nil || hdr.Name != name {
+			t.Fatalf("expected %s, got %v", name, hdr)
+		}
+		if hdr.Flags&flagDataDesc == 0 {
+			t.Fatalf("%s: expected a data descriptor", name)
+		}
+		got, err := ioutil.ReadAll(sr)
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", name, err)
+		}
+		want, err := ioutil.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !bytes.Equal(got, want) || hdr.Size != int64(len(want)) {
+			t.Errorf("%s: got %q (size %d), want %q", name, got, hdr.Size, want)
+		}
+		if _, err = hdr.Open(); err != StreamOpenError {
+			t.Errorf("%s: Open() on streamed header returned %v", name, err)
+		}
+	}
+	if hdr, err := sr.Next(); hdr != nil || err != nil {
+		t.Errorf("expected end of entries, got %v, %v", hdr, err)
+	}
+	fmt.Printf("TestStreamDescriptor fini\n")
+}
+
+// Purpose: a streamed bomb is stopped by the limits even though
+// nothing about its size is known until the data has been read
+func TestStreamLimits(t *testing.T) {
+	fmt.Printf("TestStreamLimits start\n")
+	f, err := os.Open("testdata/bomb.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	sr := NewStreamReader(io.MultiReader(f))
+	sr.SetLimits(Limits{MaxRatio: 100})
+	if _, err = sr.Next(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err = io.Copy(ioutil.Discard, sr)
+	if le, ok := err.(*LimitError); !ok || le.Limit != "MaxRatio" {
+		t.Errorf("expected MaxRatio, got %v", err)
+	}
+	if _, err = sr.Next(); err == nil {
+		t.Errorf("Next() after a limit error should keep failing")
+	}
+	fmt.Printf("TestStreamLimits fini\n")
+}
+
+// Purpose: MaxRatio is checked against the compressed bytes read so far, an
+// ordinary archive streams through without tripping it
+func TestStreamRatio(t *testing.T) {
+	fmt.Printf("TestStreamRatio start\n")
+	f, err := os.Open("testdata/phpBB.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	sr := NewStreamReader(io.MultiReader(f))
+	sr.SetLimits(Limits{MaxRatio: 20})
+	entries := 0
+	for {
+		hdr, err := sr.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if hdr == nil {
+			break
+		}
+		if _, err = io.Copy(ioutil.Discard, sr); err != nil {
+			t.Fatalf("%s: Unexpected error: %v", hdr.Name, err)
+		}
+		entries++
+	}
+	if entries == 0 {
+		t.Errorf("no entries streamed")
+	}
+	fmt.Printf("TestStreamRatio fini\n")
+}
+
+// Purpose: encrypted entries are refused, not decompressed as ciphertext
+func TestStreamEncrypted(t *testing.T) {
+	fmt.Printf("TestStreamEncrypted start\n")
+	f, err := os.Open("testdata/crypt.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	sr := NewStreamReader(io.MultiReader(f))
+	hdr, err := sr.Next()
+	if err != StreamCryptError || hdr == nil || !hdr.Encrypted() {
+		t.Errorf("expected an encrypted header and StreamCryptError, got %v, %v", hdr, err)
+	}
+	fmt.Printf("TestStreamEncrypted fini\n")
+}
+
+const methodPadded = 201 // not a real method, a length, the data and padding
+
+// paddedWriter writes what it was given with its length in front and
+// padding after, which the decompressor never reads
+type paddedWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (p *paddedWriter) Write(b []byte) (int, error) { return p.buf.Write(b) }
+
+func (p *paddedWriter) Close() error {
+	n := make([]byte, 4)
+	binary.LittleEndian.PutUint32(n, uint32(p.buf.Len()))
+	_, err := p.w.Write(append(append(n, p.buf.Bytes()...), "padding"...))
+	return err
+}
+
+// paddedReader reads the length then that much data, leaving the padding
+type paddedReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (p *paddedReader) Read(b []byte) (int, error) {
+	if p.left < 0 {
+		n := make([]byte, 4)
+		if _, err := io.ReadFull(p.r, n); err != nil {
+			return 0, err
+		}
+		p.left = int64(binary.LittleEndian.Uint32(n))
+	}
+	if p.left == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > p.left {
+		b = b[:p.left]
+	}
+	n, err := p.r.Read(b)
+	p.left -= int64(n)
+	return n, err
+}
+
+func (p *paddedReader) Close() error { return nil }
+
+// Purpose: what a registered decompressor leaves unread of an entry is
+// skipped like deflate's, so the next header is found
+func TestStreamRegistered(t *testing.T) {
+	fmt.Printf("TestStreamRegistered start\n")
+	RegisterDecompressor(methodPadded, func(r io.Reader) io.ReadCloser { return &paddedReader{r: r, left: -1} })
+	defer func() {
+		methodLock.Lock()
+		delete(decompressors, methodPadded)
+		methodLock.Unlock()
+	}()
+	var buf bytes.Buffer
+	zw := NewWriter(&buf)
+	zw.RegisterCompressor(methodPadded, func(w io.Writer) (io.WriteCloser, error) { return &paddedWriter{w: w}, nil })
+	for _, name := range []string{"one.txt", "two.txt"} {
+		fw, err := zw.Create(&Header{Name: name, Compress: methodPadded})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		fmt.Fprintf(fw, "contents of %s\n", name)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sr := NewStreamReader(bytes.NewReader(buf.Bytes()))
+	var got []string
+	for {
+		hdr, err := sr.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if hdr == nil {
+			break
+		}
+		data, err := ioutil.ReadAll(sr)
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", hdr.Name, err)
+		}
+		got = append(got, string(data))
+	}
+	if len(got) != 2 || got[1] != "contents of two.txt\n" {
+		t.Errorf("streamed %q", got)
+	}
+	fmt.Printf("TestStreamRegistered fini\n")
+}
@@ -0,0 +1,138 @@
+// tar.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Converting between zip and tar, for pipelines that get one and want the
+// other.  Both directions stream, nothing is held in memory beyond one entry
+// (which the zip Writer compresses in memory anyway).  Names, permissions,
+// file types and symlink targets come through unchanged.  Modification times
+// do too, except that zip keeps them to 2 seconds.
+
+package zipfile
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	TarLinkError = errors.New("tar hard link, zip has no way to say that")
+	TarTypeError = errors.New("tar entry type has no zip equivalent")
+)
+
+// ZipToTar writes every entry of r to tw, in archive order.  Sockets, which
+// tar can't hold, are left out.  Devices have no numbers in zip so they come
+// out as 0, 0.  tw is flushed but not closed, so more can be added.
+func ZipToTar(tw *tar.Writer, r *ZipReader) error {
+	filelist, err := r.CentralHeaders()
+	if err != nil {
+		return err
+	}
+	for _, h := range filelist {
+		mode := h.Mode()
+		if mode&os.ModeSocket != 0 {
+			continue
+		}
+		th := &tar.Header{
+			Name:     h.Name,
+			Mode:     tarMode(mode),
+			ModTime:  h.ModTime(),
+			Typeflag: h.Typeflag,
+		}
+		switch h.Typeflag {
+		case TypeReg:
+			th.Size = h.Size
+		case TypeDir:
+			if !strings.HasSuffix(th.Name, dirNameSuffix) {
+				th.Name += dirNameSuffix
+			}
+		case TypeSymlink:
+			if th.Linkname, err = h.Linkname(); err != nil {
+				return err
+			}
+		}
+		if err := tw.WriteHeader(th); err != nil {
+			return err
+		}
+		if h.Typeflag != TypeReg {
+			continue
+		}
+		rdr, err := h.Open()
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, rdr); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// tarMode is the permission bits of mode as tar stores them, the same as
+// st_mode without the file type
+func tarMode(mode os.FileMode) int64 {
+	m := int64(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= s_ISUID
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= s_ISGID
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= s_ISVTX
+	}
+	return m
+}
+
+// TarToZip reads tr to the end, adding each entry to zw compressed with
+// method (directories, symlinks and devices are stored).  Hard links fail
+// with TarLinkError, as zip can only hold a second copy and the first may be
+// long gone, and other types tar has but zip doesn't with TarTypeError.  zw
+// isn't closed, so more can be added.
+func TarToZip(zw *Writer, tr *tar.Reader, method uint16) error {
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		h := &Header{Name: th.Name, Mtime: th.ModTime, Compress: method}
+		switch th.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+		case tar.TypeDir:
+			if !strings.HasSuffix(h.Name, dirNameSuffix) {
+				h.Name += dirNameSuffix
+			}
+		case tar.TypeSymlink, tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			h.Compress = ZIP_STORED
+		case tar.TypeXGlobalHeader:
+			continue // archive/tar has already applied it
+		case tar.TypeLink:
+			return TarLinkError
+		default:
+			return TarTypeError
+		}
+		h.SetMode(th.FileInfo().Mode())
+		fw, err := zw.Create(h)
+		if err != nil {
+			return err
+		}
+		switch th.Typeflag {
+		case tar.TypeSymlink:
+			_, err = io.WriteString(fw, th.Linkname)
+		case tar.TypeReg, tar.TypeRegA:
+			_, err = io.Copy(fw, tr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
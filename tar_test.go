@@ -0,0 +1,97 @@
+// tar_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// Purpose: zip to tar and back again gives the archive we started with
+func TestTarRoundTrip(t *testing.T) {
+	fmt.Printf("TestTarRoundTrip start\n")
+	f, err := os.Open("testdata/unix.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	if err := ZipToTar(tw, rz); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tw.Close()
+
+	tr := tar.NewReader(bytes.NewReader(tarBuf.Bytes()))
+	links := 0
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if th.Name == "unix/link.txt" {
+			links++
+			if th.Typeflag != tar.TypeSymlink || th.Linkname != "readme.txt" {
+				t.Errorf("link.txt is type %c to %q, expected a symlink to readme.txt", th.Typeflag, th.Linkname)
+			}
+		}
+	}
+	if links != 1 {
+		t.Errorf("found %d link.txt in the tar, expected 1", links)
+	}
+
+	var zipBuf bytes.Buffer
+	zw := NewWriter(&zipBuf)
+	if err := TarToZip(zw, tar.NewReader(bytes.NewReader(tarBuf.Bytes())), ZIP_DEFLATED); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	back, err := NewReader(bytes.NewReader(zipBuf.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	diffs, err := Diff(rz, back, &DiffOptions{IgnoreMethod: true, Content: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, d := range diffs {
+		t.Errorf("%s %s: %v", d.Kind, d.Name, d.Changes)
+	}
+	fmt.Printf("TestTarRoundTrip fini\n")
+}
+
+// Purpose: hard links are refused rather than quietly made empty
+func TestTarLink(t *testing.T) {
+	fmt.Printf("TestTarLink start\n")
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	mtime := time.Date(2012, 3, 4, 5, 6, 8, 0, time.UTC)
+	tw.WriteHeader(&tar.Header{Name: "a", Mode: 0644, Size: 1, ModTime: mtime, Typeflag: tar.TypeReg})
+	tw.Write([]byte("a"))
+	tw.WriteHeader(&tar.Header{Name: "b", Mode: 0644, ModTime: mtime, Typeflag: tar.TypeLink, Linkname: "a"})
+	tw.Close()
+	zw := NewWriter(new(bytes.Buffer))
+	if err := TarToZip(zw, tar.NewReader(&tarBuf), ZIP_DEFLATED); err != TarLinkError {
+		t.Errorf("expected TarLinkError, got %v", err)
+	}
+	fmt.Printf("TestTarLink fini\n")
+}
@@ -0,0 +1,363 @@
+// volume.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Multi-volume (spanned diskette) and split archives.  Info-ZIP and PKZIP
+// write these as name.z01, name.z02 ... with the last piece named name.zip.
+// The central directory is on the last volume and every offset in it is
+// relative to the start of the volume named by the matching disk number.
+//
+// Volumes glues the pieces together into one logical stream, so entries that
+// start on one volume and finish on the next need no special handling, and
+// a ZipReader made with NewVolumeReader translates the (disk, offset) pairs
+// from the central directory into positions in that stream.
+
+package zipfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	NoVolumesError  = errors.New("no volumes given")
+	BadDiskNbrError = errors.New("disk number in archive is past the last volume")
+)
+
+// Volumes is an ordered set of archive pieces read as one.  It implements
+// io.ReaderAt and io.ReadSeeker.
+type Volumes struct {
+	vols   []io.ReaderAt
+	starts []int64 // logical offset of the first byte of each volume
+	size   int64
+	pos    int64
+	files  []*os.File // only when we opened them, for Close
+}
+
+// NewVolumes joins vols, which must be in order with sizes[i] the length of vols[i]
+func NewVolumes(vols []io.ReaderAt, sizes []int64) (*Volumes, error) {
+	if len(vols) == 0 || len(vols) != len(sizes) {
+		return nil, NoVolumesError
+	}
+	v := &Volumes{vols: vols, starts: make([]int64, len(vols))}
+	for ndx, size := range sizes {
+		v.starts[ndx] = v.size
+		v.size += size
+	}
+	return v, nil
+}
+
+// OpenVolumes opens the named files, in the order given
+func OpenVolumes(names []string) (*Volumes, error) {
+	vols := make([]io.ReaderAt, 0, len(names))
+	sizes := make([]int64, 0, len(names))
+	files := make([]*os.File, 0, len(names))
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err == nil {
+			var fi os.FileInfo
+			if fi, err = f.Stat(); err == nil {
+				vols = append(vols, f)
+				sizes = append(sizes, fi.Size())
+				files = append(files, f)
+				continue
+			}
+			f.Close()
+		}
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, err
+	}
+	v, err := NewVolumes(vols, sizes)
+	if err != nil {
+		return nil, err
+	}
+	v.files = files
+	return v, nil
+}
+
+// SplitNames finds the pieces of a split archive given the name of the last
+// one (name.zip), by looking for name.z01, name.z02 ... until one is missing
+func SplitNames(name string) []string {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	var names []string
+	for n := 1; ; n++ {
+		piece := fmt.Sprintf("%s.z%02d", base, n)
+		if _, err := os.Stat(piece); err != nil {
+			break
+		}
+		names = append(names, piece)
+	}
+	return append(names, name)
+}
+
+// OpenSplit opens a split archive by the name of its last piece.  An archive
+// that was never split is one volume, NewVolumeReader reads it like any
+// other file, self-extractor prefix and all.
+func OpenSplit(name string) (*Volumes, error) {
+	return OpenVolumes(SplitNames(name))
+}
+
+// Close closes any files OpenVolumes opened
+func (v *Volumes) Close() error {
+	var err error
+	for _, f := range v.files {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Size is the combined length of all volumes
+func (v *Volumes) Size() int64 {
+	return v.size
+}
+
+// Count is the number of volumes
+func (v *Volumes) Count() int {
+	return len(v.vols)
+}
+
+// Logical converts an offset on a disk (volume number, counting from 0) to an
+// offset in the joined stream
+func (v *Volumes) Logical(disk int, off int64) (int64, error) {
+	if disk < 0 || disk >= len(v.vols) {
+		return 0, BadDiskNbrError
+	}
+	return v.starts[disk] + off, nil
+}
+
+// volumeAt finds the volume holding logical offset off
+func (v *Volumes) volumeAt(off int64) int {
+	ndx := len(v.starts) - 1
+	for ndx > 0 && v.starts[ndx] > off {
+		ndx--
+	}
+	return ndx
+}
+
+// ReadAt reads from as many volumes as it takes to fill p
+func (v *Volumes) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	n := 0
+	for n < len(p) && off < v.size {
+		ndx := v.volumeAt(off)
+		volEnd := v.size
+		if ndx+1 < len(v.starts) {
+			volEnd = v.starts[ndx+1]
+		}
+		want := p[n:]
+		if int64(len(want)) > volEnd-off {
+			want = want[:volEnd-off]
+		}
+		got, err := v.vols[ndx].ReadAt(want, off-v.starts[ndx])
+		n += got
+		off += int64(got)
+		if err == io.EOF && got == len(want) {
+			err = nil // end of this volume, go on to the next
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF // volume shorter than when we sized it
+			}
+			return n, err
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (v *Volumes) Read(p []byte) (int, error) {
+	if v.pos >= v.size {
+		return 0, io.EOF
+	}
+	n, err := v.ReadAt(p, v.pos)
+	v.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (v *Volumes) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case 0:
+		// offset is already absolute
+	case 1:
+		offset += v.pos
+	case 2:
+		offset += v.size
+	default:
+		return 0, errors.New("bad whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	v.pos = offset
+	return offset, nil
+}
+
+// NewVolumeReader returns a reader for a multi-volume or split archive.
+// Both Headers() and CentralHeaders() work, CentralHeaders() uses the disk
+// numbers in the directory to find each entry.
+func NewVolumeReader(v *Volumes) (*ZipReader, error) {
+	x, err := NewReader(v)
+	if err != nil {
+		return nil, err
+	}
+	x.volumes = v
+	return x, nil
+}
+
+// split is true when r reads more than one volume.  One volume is treated
+// as an ordinary file, so OpenSplit works on archives that were never split.
+func (r *ZipReader) split() bool {
+	return r.volumes != nil && r.volumes.Count() > 1
+}
+
+// logical turns a disk number and offset from the directory into a position
+// in r.reader.  A reader on a single file ignores the disk number.
+func (r *ZipReader) logical(disk uint16, off int64) (int64, error) {
+	if !r.split() {
+		return off + r.shift, nil
+	}
+	return r.volumes.Logical(int(disk), off)
+}
+
+// isSpanningSig is true for the markers that can start a split archive
+func isSpanningSig(localHdr []byte) bool {
+	sig := string(localHdr[0:4])
+	return sig == ZIP_SpanningSig || sig == ZIP_SpannedSig
+}
+
+// MinVolumeSize is the smallest piece NewSplitWriter will make, APPNOTE.TXT
+// says a segment must hold at least 64K
+const MinVolumeSize = 64 * 1024
+
+var VolumeSizeError = errors.New("volume size too small")
+
+// NewSplitWriter returns a Writer that produces a split archive.  Pieces are
+// written as name.z01, name.z02 ... each maxSize bytes, and the last one,
+// which holds the central directory, is named name itself.  If everything
+// fits in one piece the result is an ordinary archive (with the "PK00"
+// marker the spec asks for).  Close the Writer to finish the last piece.
+func NewSplitWriter(name string, maxSize int64) (*Writer, error) {
+	if maxSize < MinVolumeSize {
+		return nil, VolumeSizeError
+	}
+	s := &splitWriter{name: name, max: maxSize, nbr: -1}
+	if err := s.next(); err != nil {
+		return nil, err
+	}
+	// the spanning signature starts the first piece, offsets count it
+	if _, err := io.WriteString(s, ZIP_SpanningSig); err != nil {
+		s.finish(false)
+		return nil, err
+	}
+	return &Writer{out: s}, nil
+}
+
+// splitWriter is the volumeWriter for split archives
+type splitWriter struct {
+	name string // final name, pieces before it are .z01, .z02 ...
+	max  int64
+	f    *os.File
+	nbr  int   // current piece, counting from 0
+	n    int64 // bytes in the current piece
+}
+
+func (s *splitWriter) pieceName(nbr int) string {
+	base := strings.TrimSuffix(s.name, filepath.Ext(s.name))
+	return fmt.Sprintf("%s.z%02d", base, nbr+1)
+}
+
+// next closes the current piece and starts another
+func (s *splitWriter) next() error {
+	if s.f != nil {
+		err := s.f.Close()
+		s.f = nil
+		if err != nil {
+			return err
+		}
+	}
+	s.nbr++
+	if s.nbr > maxUint16 {
+		return Zip64Error
+	}
+	f, err := os.Create(s.pieceName(s.nbr))
+	if err != nil {
+		return err
+	}
+	s.f = f
+	s.n = 0
+	return nil
+}
+
+func (s *splitWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if s.n == s.max {
+			if err := s.next(); err != nil {
+				return written, err
+			}
+		}
+		chunk := p
+		if room := s.max - s.n; int64(len(chunk)) > room {
+			chunk = chunk[:room]
+		}
+		n, err := s.f.Write(chunk)
+		written += n
+		s.n += int64(n)
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (s *splitWriter) disk() int     { return s.nbr }
+func (s *splitWriter) offset() int64 { return s.n }
+
+func (s *splitWriter) reserve(n int64) error {
+	if n > s.max {
+		return VolumeSizeError
+	}
+	if s.n+n > s.max {
+		return s.next()
+	}
+	return nil
+}
+
+// finish closes the last piece and, if ok, gives it the archive's real name
+func (s *splitWriter) finish(ok bool) error {
+	if s.f == nil {
+		return nil // next couldn't create it, that error came first
+	}
+	var err error
+	if ok && s.nbr == 0 {
+		// never split after all, change the marker to say so
+		_, err = s.f.WriteAt([]byte(ZIP_SpannedSig), 0)
+	}
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	s.f = nil
+	if ok && err == nil {
+		err = os.Rename(s.pieceName(s.nbr), s.name)
+	}
+	return err
+}
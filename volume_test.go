@@ -0,0 +1,236 @@
+// volume_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Purpose: read an Info-ZIP split archive (zip -s 64k), three volumes
+// with entries crossing volume boundaries, and compare with phpBB.zip
+func TestSplitRead(t *testing.T) {
+	fmt.Printf("TestSplitRead start\n")
+	names := SplitNames("testdata/split.zip")
+	if len(names) != 3 || names[0] != "testdata/split.z01" {
+		t.Fatalf("SplitNames found %v", names)
+	}
+	v, err := OpenSplit("testdata/split.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer v.Close()
+	rz, err := NewVolumeReader(v)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	central, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	local, err := rz.Headers()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(central) != 48 || len(local) != len(central) {
+		t.Fatalf("central %d entries, local %d, want 48", len(central), len(local))
+	}
+	spanning := 0
+	for ndx, hdr := range central {
+		if hdr.LocalOffset != local[ndx].LocalOffset {
+			t.Fatalf("%s: central offset %d, local scan found %d", hdr.Name, hdr.LocalOffset, local[ndx].LocalOffset)
+		}
+		if hdr.Typeflag != TypeReg {
+			continue
+		}
+		off, _ := hdr.DataOffset()
+		if v.volumeAt(hdr.LocalOffset) != v.volumeAt(off+hdr.SizeCompr-1) {
+			spanning++
+		}
+		rdr, err := hdr.Open()
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", hdr.Name, err)
+		}
+		got, _ := ioutil.ReadAll(rdr)
+		if want := contentsOf(t, "testdata/phpBB.zip", hdr.Name); !bytes.Equal(got, want) {
+			t.Fatalf("%s: contents differ", hdr.Name)
+		}
+	}
+	if spanning == 0 {
+		t.Errorf("no entries crossed a volume boundary, test archive isn't testing much")
+	}
+	if _, err = v.Logical(3, 0); err != BadDiskNbrError {
+		t.Errorf("expected BadDiskNbrError, got %v", err)
+	}
+	fmt.Printf("TestSplitRead fini\n")
+}
+
+// Purpose: OpenSplit on an archive that was never split reads it as an
+// ordinary file, a self-extractor's prefix found the same way
+func TestSplitSingle(t *testing.T) {
+	fmt.Printf("TestSplitSingle start\n")
+	f, err := os.Open("testdata/sfx.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	v, err := OpenSplit("testdata/sfx.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer v.Close()
+	if v.Count() != 1 {
+		t.Fatalf("%d volumes, expected 1", v.Count())
+	}
+	vz, err := NewVolumeReader(v)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	central, err := vz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	local, err := vz.Headers()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(central) != len(want) || len(local) != len(want) {
+		t.Fatalf("central %d entries, local %d, expected %d", len(central), len(local), len(want))
+	}
+	for ndx, hdr := range central {
+		if hdr.Name != want[ndx].Name || hdr.LocalOffset != want[ndx].LocalOffset || local[ndx].LocalOffset != hdr.LocalOffset {
+			t.Errorf("%s: offset %d, local %d, expected %d", hdr.Name, hdr.LocalOffset, local[ndx].LocalOffset, want[ndx].LocalOffset)
+		}
+		if _, err = hdr.Open(); err != nil {
+			t.Errorf("%s: Unexpected error: %v", hdr.Name, err)
+		}
+	}
+	fmt.Printf("TestSplitSingle fini\n")
+}
+
+// Purpose: write a split archive with NewSplitWriter and read it back
+// with OpenSplit, entries cross the 64K volume boundaries
+func TestSplitWrite(t *testing.T) {
+	fmt.Printf("TestSplitWrite start\n")
+	dir, err := ioutil.TempDir("", "zipsplit")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "out.zip")
+	w, err := NewSplitWriter(name, MinVolumeSize)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// stored so the data is big enough to need several pieces
+	big := bytes.Repeat([]byte("0123456789abcdef"), 6000)
+	for i := 0; i < 3; i++ {
+		fw, err := w.Create(&Header{Name: fmt.Sprintf("big%d", i), Compress: ZIP_STORED})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		fw.Write(big)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	names := SplitNames(name)
+	if len(names) != 5 {
+		t.Fatalf("expected 5 pieces, got %v", names)
+	}
+	for _, piece := range names[:len(names)-1] {
+		if fi, err := os.Stat(piece); err != nil || fi.Size() != MinVolumeSize {
+			t.Errorf("%s: size %v, %v", piece, fi.Size(), err)
+		}
+	}
+	v, err := OpenSplit(name)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer v.Close()
+	rz, err := NewVolumeReader(v)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	central, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	local, err := rz.Headers()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(central) != 3 || len(local) != 3 {
+		t.Fatalf("expected 3 entries, got %d central %d local", len(central), len(local))
+	}
+	for ndx, hdr := range central {
+		if hdr.LocalOffset != local[ndx].LocalOffset {
+			t.Errorf("%s: central offset %d, local scan %d", hdr.Name, hdr.LocalOffset, local[ndx].LocalOffset)
+		}
+		rdr, err := hdr.Open()
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", hdr.Name, err)
+		}
+		got, _ := ioutil.ReadAll(rdr)
+		if !bytes.Equal(got, big) {
+			t.Errorf("%s: contents differ", hdr.Name)
+		}
+	}
+
+	// small enough for one piece, no .z01 and an ordinary archive
+	one := filepath.Join(dir, "one.zip")
+	w, err = NewSplitWriter(one, MinVolumeSize)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	writeTestArchive(t, w)
+	if names = SplitNames(one); len(names) != 1 {
+		t.Fatalf("expected one piece, got %v", names)
+	}
+	f, err := os.Open(one)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	if rz, err = NewReader(f); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	checkTestArchive(t, rz)
+
+	// a failed Close still closes the piece and doesn't name it as finished
+	failed := filepath.Join(dir, "failed.zip")
+	w, err = NewSplitWriter(failed, MinVolumeSize)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	piece := w.out.(*splitWriter).f
+	w.Comment = strings.Repeat("x", maxUint16+1)
+	if err = w.Close(); err != Zip64Error {
+		t.Errorf("long comment: expected Zip64Error, got %v", err)
+	}
+	if _, err = piece.Stat(); err == nil {
+		t.Errorf("piece still open after a failed Close")
+	}
+	if _, err = os.Stat(failed); err == nil {
+		t.Errorf("failed archive was renamed into place")
+	}
+	fmt.Printf("TestSplitWrite fini\n")
+}
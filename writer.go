@@ -0,0 +1,612 @@
+// writer.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+//
+// Writer creates zip archives.  Each entry is compressed into memory and only
+// written out, with its real sizes and CRC in the local header, when the next
+// entry is started or the Writer is closed.  That costs memory for big entries
+// but it means the output never has to seek, no data descriptors are needed,
+// and Next() can walk the local headers of anything we write.  CreateRaw does
+// keep the descriptor of an encrypted entry, the sizes go in the local header
+// as well so Next() can still step over it.
+
+package zipfile
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	zipVersion20  = 20 // 2.0, deflate and directories
+	maxUint32     = 1<<32 - 1
+	maxUint16     = 1<<16 - 1
+	unixMadeBy    = creatorUnix<<8 | zipVersion20
+	dirNameSuffix = "/"
+
+	flagUTF8        = 0x0800 // names and comments are UTF-8
+	flagDeflateOpts = 0x0006 // compression option bits, worth keeping with the data
+)
+
+var (
+	WriterClosedError = errors.New("write to closed zip Writer")
+	DirWriteError     = errors.New("can't write data to a directory entry")
+	Zip64Error        = errors.New("archive needs zip64 extensions, not supported")
+	NameLenError      = errors.New("entry name, comment or extra field longer than 65535 bytes")
+	EpochError        = errors.New("SOURCE_DATE_EPOCH is not a number of seconds")
+)
+
+// Writer writes a zip archive.  Call Create for each entry, write the entry's
+// contents to the io.Writer it returns, then Close to write the central
+// directory.
+//
+// A Deterministic Writer makes archives that depend only on the names, types
+// and contents of the entries, for build systems that hash what they make.
+// Entries are held until Close and written sorted by name, every Mtime
+// becomes Epoch, permissions become 0644 (0755 for directories and anything
+// executable) and the archive says it was made on unix.  Otherwise a
+// Header's Extra is written to both headers as it is, less any zip64 record
+// (sizes and offsets this Writer doesn't use); a Deterministic Writer writes
+// none.
+type Writer struct {
+	Comment       string    // archive comment, written by Close
+	Deterministic bool      // see above, set before the first Create
+	Epoch         time.Time // Mtime of every entry when Deterministic, zero means $SOURCE_DATE_EPOCH or 1980-01-01
+
+	out    volumeWriter
+	dir    []*dirEntry
+	cur    *dirEntry
+	buf    bytes.Buffer   // compressed data for cur
+	comp   io.WriteCloser // compressor feeding buf, nil for stored
+	crc    hash.Hash32
+	size   int64
+	raw    bool                  // cur came from CreateRaw, buf gets the data as it is
+	held   []heldEntry           // finished entries waiting for Close when Deterministic
+	names  map[string]bool       // names already used, only kept by OpenAppend
+	comps  map[uint16]Compressor // this Writer's own compressors, see RegisterCompressor
+	closed bool
+}
+
+// heldEntry is a finished entry and its compressed data
+type heldEntry struct {
+	e    *dirEntry
+	data []byte
+}
+
+// dirEntry is what the central directory needs to know about an entry
+type dirEntry struct {
+	hdr    *Header
+	disk   int    // volume holding the local header
+	offset int64  // of the local header, relative to the start of disk
+	raw    []byte // central record copied from an existing archive, see OpenAppend
+}
+
+// NewWriter returns a Writer writing a single file archive to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{out: &countWriter{w: w}}
+}
+
+// RegisterCompressor makes method available to this Writer only, ahead of
+// the package's compressors.  Registering ZIP_DEFLATED changes the level of
+// one archive without changing every other Writer's.
+func (w *Writer) RegisterCompressor(method uint16, c Compressor) {
+	if w.comps == nil {
+		w.comps = make(map[uint16]Compressor)
+	}
+	w.comps[method] = c
+}
+
+// compressor returns the compressor Create uses for method, nil if none
+func (w *Writer) compressor(method uint16) Compressor {
+	if c := w.comps[method]; c != nil {
+		return c
+	}
+	return compressor(method)
+}
+
+// Create starts a new entry and returns a writer for its contents.  Name and
+// Mtime come from h, Compress picks ZIP_STORED, ZIP_DEFLATED or a method added
+// with RegisterCompressor (the package's or the Writer's), and if h has no attributes set (see SetMode) it gets
+// 0644, or 0755 for a name ending in "/" which makes it a directory.  Size,
+// SizeCompr and StoredCrc32 are filled in as the data is written.
+func (w *Writer) Create(h *Header) (io.Writer, error) {
+	if err := w.start(h, flagUTF8); err != nil {
+		return nil, err
+	}
+	if h.Typeflag == TypeDir {
+		h.Compress = ZIP_STORED
+	}
+	var comp Compressor
+	if h.Compress != ZIP_STORED {
+		if comp = w.compressor(h.Compress); comp == nil {
+			return nil, InvalidCompError
+		}
+	}
+	w.begin(h, false)
+	if comp != nil {
+		cw, err := comp(&w.buf)
+		if err != nil {
+			return nil, err
+		}
+		w.comp = cw
+	}
+	return entryWriter{w}, nil
+}
+
+// CreateRaw starts an entry whose data is already compressed, as it comes
+// from Header.OpenRaw.  h.Compress, StoredCrc32 and Size must describe the
+// data, which is copied through untouched, SizeCompr is set from what is
+// written.  The method doesn't need a compressor registered.  Encrypted
+// entries stay encrypted, with a data descriptor if they had one, because
+// the password check depends on it.
+func (w *Writer) CreateRaw(h *Header) (io.Writer, error) {
+	keep := uint16(flagUTF8 | flagEncrypted | flagDeflateOpts)
+	if h.Encrypted() {
+		keep |= flagDataDesc
+	}
+	if err := w.start(h, keep); err != nil {
+		return nil, err
+	}
+	w.begin(h, true)
+	return entryWriter{w}, nil
+}
+
+// start finishes the previous entry and fills in what h needs before it is
+// written, flags not in keep are cleared
+func (w *Writer) start(h *Header, keep uint16) error {
+	if w.closed {
+		return WriterClosedError
+	}
+	if err := w.finishEntry(); err != nil {
+		return err
+	}
+	if len(h.Name) > maxUint16 || len(h.Comment) > maxUint16 || len(h.Extra) > maxUint16 {
+		return NameLenError
+	}
+	if w.names[h.Name] {
+		return DuplicateNameError
+	}
+	if h.VersionMadeBy == 0 && h.ExternalAttrs == 0 {
+		if strings.HasSuffix(h.Name, dirNameSuffix) {
+			h.SetMode(os.ModeDir | 0755)
+		} else {
+			h.SetMode(0644)
+		}
+	}
+	if h.Mtime.IsZero() {
+		h.Mtime = time.Now()
+	}
+	if w.Deterministic {
+		if err := w.normalize(h); err != nil {
+			return err
+		}
+	}
+	h.Extra = withoutExtra(h.Extra, extZip64ID)
+	h.dosDate, h.dosTime = makeDosDate(h.Mtime)
+	h.Typeflag = h.typeflag()
+	h.Flags &= keep // data descriptors etc. don't apply to what we write
+	if !isASCII(h.Name+h.Comment) && utf8.ValidString(h.Name+h.Comment) {
+		h.Flags |= flagUTF8
+	}
+	return nil
+}
+
+// begin makes h the current entry
+func (w *Writer) begin(h *Header, raw bool) {
+	w.cur = &dirEntry{hdr: h}
+	if w.names != nil {
+		w.names[h.Name] = true
+	}
+	w.buf.Reset()
+	w.crc = crc32.NewIEEE()
+	w.size = 0
+	w.comp = nil
+	w.raw = raw
+}
+
+// entryWriter takes the contents of the current entry
+type entryWriter struct {
+	w *Writer
+}
+
+func (e entryWriter) Write(p []byte) (int, error) {
+	w := e.w
+	if w.closed {
+		return 0, WriterClosedError
+	}
+	if w.cur == nil {
+		return 0, errors.New("write to finished zip entry")
+	}
+	if w.cur.hdr.Typeflag == TypeDir && len(p) > 0 {
+		return 0, DirWriteError
+	}
+	if w.raw {
+		return w.buf.Write(p)
+	}
+	w.crc.Write(p)
+	w.size += int64(len(p))
+	if w.comp != nil {
+		return w.comp.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+// finishEntry writes the pending entry's local header and data
+func (w *Writer) finishEntry() error {
+	if w.cur == nil {
+		return nil
+	}
+	if w.comp != nil {
+		if err := w.comp.Close(); err != nil {
+			return err
+		}
+	}
+	h := w.cur.hdr
+	h.SizeCompr = int64(w.buf.Len())
+	if !w.raw {
+		h.Size = w.size
+		h.StoredCrc32 = w.crc.Sum32()
+	}
+	if h.Size > maxUint32 || h.SizeCompr > maxUint32 {
+		return Zip64Error
+	}
+	e := w.cur
+	w.cur = nil
+	if w.Deterministic {
+		w.held = append(w.held, heldEntry{e, append([]byte(nil), w.buf.Bytes()...)})
+		return nil
+	}
+	return w.writeEntry(e, w.buf.Bytes())
+}
+
+// writeEntry writes a finished entry's local header and data
+func (w *Writer) writeEntry(e *dirEntry, data []byte) error {
+	if err := w.writeLocal(e); err != nil {
+		return err
+	}
+	if _, err := w.out.Write(data); err != nil {
+		return err
+	}
+	if h := e.hdr; h.Flags&flagDataDesc != 0 {
+		b := make([]byte, 16)
+		copy(b, ZIP_DataDescSig)
+		putThirtyTwoBit(b[4:], h.StoredCrc32)
+		putThirtyTwoBit(b[8:], uint32(h.SizeCompr))
+		putThirtyTwoBit(b[12:], uint32(h.Size))
+		if _, err := w.out.Write(b); err != nil {
+			return err
+		}
+	}
+	w.dir = append(w.dir, e)
+	return nil
+}
+
+// normalize makes h what a Deterministic Writer records
+func (w *Writer) normalize(h *Header) error {
+	if w.Epoch.IsZero() {
+		epoch, err := sourceDateEpoch()
+		if err != nil {
+			return err
+		}
+		w.Epoch = epoch
+	}
+	h.Mtime = w.Epoch
+	mode := h.Mode()
+	switch {
+	case mode&os.ModeDir != 0:
+		mode = os.ModeDir | 0755
+	case mode&os.ModeSymlink != 0:
+		mode = os.ModeSymlink | 0777
+	case mode&0111 != 0:
+		mode = 0755
+	default:
+		mode = 0644
+	}
+	h.SetMode(mode)
+	h.InternalAttrs = 0
+	h.Extra = nil
+	return nil
+}
+
+// withoutExtra returns extra less the records with the given ids, and less
+// a last record that runs past the end
+func withoutExtra(extra []byte, ids ...uint16) []byte {
+	var out []byte
+	for len(extra) >= 4 {
+		id, size := sixteenBit(extra[0:2]), int(sixteenBit(extra[2:4]))
+		if 4+size > len(extra) {
+			break
+		}
+		keep := true
+		for _, drop := range ids {
+			if id == drop {
+				keep = false
+			}
+		}
+		if keep {
+			out = append(out, extra[:4+size]...)
+		}
+		extra = extra[4+size:]
+	}
+	return out
+}
+
+// sourceDateEpoch is the reproducible-builds time stamp, or 1980-01-01 (the
+// earliest an MSDOS date can say) if it isn't set
+func sourceDateEpoch() (time.Time, error) {
+	s := os.Getenv("SOURCE_DATE_EPOCH")
+	if s == "" {
+		return time.Date(MSDOS_EPOCH, 1, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, EpochError
+	}
+	return time.Unix(secs, 0).UTC(), nil
+}
+
+type byName []heldEntry
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Less(i, j int) bool { return b[i].e.hdr.Name < b[j].e.hdr.Name }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// writeLocal writes the local header, keeping header and name on one volume
+func (w *Writer) writeLocal(e *dirEntry) error {
+	h := e.hdr
+	if err := w.out.reserve(int64(LocalHdrSize + len(h.Name) + len(h.Extra))); err != nil {
+		return err
+	}
+	e.disk, e.offset = w.out.disk(), w.out.offset()
+	if e.offset > maxUint32 {
+		return Zip64Error
+	}
+	dosDate, dosTime := makeDosDate(h.Mtime)
+	b := make([]byte, LocalHdrSize)
+	copy(b, ZIP_LocalHdrSig)
+	putSixteenBit(b[4:], zipVersion20)
+	putSixteenBit(b[6:], h.Flags)
+	putSixteenBit(b[8:], h.Compress)
+	putSixteenBit(b[10:], dosTime)
+	putSixteenBit(b[12:], dosDate)
+	putThirtyTwoBit(b[14:], h.StoredCrc32)
+	putThirtyTwoBit(b[18:], uint32(h.SizeCompr))
+	putThirtyTwoBit(b[22:], uint32(h.Size))
+	putSixteenBit(b[26:], uint16(len(h.Name)))
+	putSixteenBit(b[28:], uint16(len(h.Extra)))
+	b = append(b, h.Name...)
+	b = append(b, h.Extra...)
+	_, err := w.out.Write(b)
+	return err
+}
+
+// Close finishes the last entry and writes the central directory.  It does
+// not close the underlying io.Writer, but a Writer from OpenAppend or
+// NewSplitWriter closes its file whether or not Close succeeds.
+func (w *Writer) Close() error {
+	if w.closed {
+		return WriterClosedError
+	}
+	w.closed = true
+	err := w.writeDirectory()
+	if ferr := w.out.finish(err == nil); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// writeDirectory writes the held entries, if any, then the central directory
+func (w *Writer) writeDirectory() error {
+	if err := w.finishEntry(); err != nil {
+		return err
+	}
+	sort.Stable(byName(w.held))
+	for _, he := range w.held {
+		if err := w.writeEntry(he.e, he.data); err != nil {
+			return err
+		}
+	}
+	w.held = nil
+	if len(w.dir) > maxUint16 || len(w.Comment) > maxUint16 {
+		return Zip64Error
+	}
+	dirDisk, dirOffset := -1, int64(0)
+	var dirSize int64
+	recDisks := make([]int, 0, len(w.dir)) // volume each directory record went to
+	for _, e := range w.dir {
+		rec, err := e.centralRecord()
+		if err != nil {
+			return err
+		}
+		if err = w.out.reserve(int64(len(rec))); err != nil {
+			return err
+		}
+		if dirDisk < 0 {
+			dirDisk, dirOffset = w.out.disk(), w.out.offset()
+		}
+		recDisks = append(recDisks, w.out.disk())
+		if _, err = w.out.Write(rec); err != nil {
+			return err
+		}
+		dirSize += int64(len(rec))
+	}
+	if err := w.out.reserve(int64(EndCentDirSize + len(w.Comment))); err != nil {
+		return err
+	}
+	if dirDisk < 0 { // empty archive
+		dirDisk, dirOffset = w.out.disk(), w.out.offset()
+	}
+	if dirOffset > maxUint32 || dirSize > maxUint32 {
+		return Zip64Error
+	}
+	lastDisk := w.out.disk()
+	onLastDisk := 0
+	for _, disk := range recDisks {
+		if disk == lastDisk {
+			onLastDisk++
+		}
+	}
+	b := make([]byte, EndCentDirSize)
+	copy(b, ZIP_EndCentDirSig)
+	putSixteenBit(b[4:], uint16(lastDisk))
+	putSixteenBit(b[6:], uint16(dirDisk))
+	putSixteenBit(b[8:], uint16(onLastDisk))
+	putSixteenBit(b[10:], uint16(len(w.dir)))
+	putThirtyTwoBit(b[12:], uint32(dirSize))
+	putThirtyTwoBit(b[16:], uint32(dirOffset))
+	putSixteenBit(b[20:], uint16(len(w.Comment)))
+	if _, err := w.out.Write(b); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.out, w.Comment)
+	return err
+}
+
+// centralRecord builds the central directory record for an entry
+func (e *dirEntry) centralRecord() ([]byte, error) {
+	if e.raw != nil {
+		return e.raw, nil
+	}
+	h := e.hdr
+	if e.disk > maxUint16 {
+		return nil, Zip64Error
+	}
+	dosDate, dosTime := makeDosDate(h.Mtime)
+	b := make([]byte, CentDirHdrSize, CentDirHdrSize+len(h.Name)+len(h.Extra)+len(h.Comment))
+	copy(b, ZIP_CentDirSig)
+	putSixteenBit(b[4:], h.VersionMadeBy)
+	putSixteenBit(b[6:], zipVersion20)
+	putSixteenBit(b[8:], h.Flags)
+	putSixteenBit(b[10:], h.Compress)
+	putSixteenBit(b[12:], dosTime)
+	putSixteenBit(b[14:], dosDate)
+	putThirtyTwoBit(b[16:], h.StoredCrc32)
+	putThirtyTwoBit(b[20:], uint32(h.SizeCompr))
+	putThirtyTwoBit(b[24:], uint32(h.Size))
+	putSixteenBit(b[28:], uint16(len(h.Name)))
+	putSixteenBit(b[30:], uint16(len(h.Extra)))
+	putSixteenBit(b[32:], uint16(len(h.Comment)))
+	putSixteenBit(b[34:], uint16(e.disk))
+	putSixteenBit(b[36:], h.InternalAttrs)
+	putThirtyTwoBit(b[38:], h.ExternalAttrs)
+	putThirtyTwoBit(b[42:], uint32(e.offset))
+	b = append(b, h.Name...)
+	b = append(b, h.Extra...)
+	b = append(b, h.Comment...)
+	return b, nil
+}
+
+// SetMode records mode as unix permissions and file type, the way Info-ZIP
+// does, and sets Typeflag to match.  Directories also get the MSDOS
+// directory bit so non-unix tools see them.
+func (h *Header) SetMode(mode os.FileMode) {
+	m := uint32(mode.Perm())
+	switch {
+	case mode&os.ModeDir != 0:
+		m |= s_IFDIR
+	case mode&os.ModeSymlink != 0:
+		m |= s_IFLNK
+	case mode&os.ModeNamedPipe != 0:
+		m |= s_IFIFO
+	case mode&os.ModeSocket != 0:
+		m |= s_IFSOCK
+	case mode&os.ModeCharDevice != 0:
+		m |= s_IFCHR
+	case mode&os.ModeDevice != 0:
+		m |= s_IFBLK
+	default:
+		m |= s_IFREG
+	}
+	if mode&os.ModeSetuid != 0 {
+		m |= s_ISUID
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= s_ISGID
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= s_ISVTX
+	}
+	h.VersionMadeBy = unixMadeBy
+	h.ExternalAttrs = m << 16
+	if mode&os.ModeDir != 0 {
+		h.ExternalAttrs |= msdosDir
+	}
+	if mode&0200 == 0 {
+		h.ExternalAttrs |= msdosReadOnly
+	}
+	h.Typeflag = h.typeflag()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// makeDosDate is makeGoDate in reverse.  MSDOS can't do dates before 1980
+// and only keeps even seconds.
+func makeDosDate(t time.Time) (d, tm uint16) {
+	t = t.UTC() // makeGoDate treats the fields as UTC
+	if t.Year() < MSDOS_EPOCH {
+		return 1<<5 | 1, 0 // 1980-01-01 00:00:00
+	}
+	d = uint16(t.Year()-MSDOS_EPOCH)<<9 | uint16(t.Month())<<5 | uint16(t.Day())
+	tm = uint16(t.Hour())<<11 | uint16(t.Minute())<<5 | uint16(t.Second()/2)
+	return d, tm
+}
+
+// putSixteenBit is sixteenBit in reverse, little endian
+func putSixteenBit(b []byte, v uint16) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+}
+
+// putThirtyTwoBit is thirtyTwoBit in reverse, little endian
+func putThirtyTwoBit(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+	b[3] = byte(v >> 24)
+}
+
+// volumeWriter is where a Writer's bytes go.  Split archives need to know
+// which volume a header landed on and to keep headers from being cut in two.
+type volumeWriter interface {
+	io.Writer
+	disk() int             // current volume, counting from 0
+	offset() int64         // bytes written to the current volume
+	reserve(n int64) error // make sure the next n bytes go to one volume
+	finish(ok bool) error  // all done, ok if the archive is complete; files are closed either way
+}
+
+// countWriter is the volumeWriter for an ordinary single file archive
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countWriter) disk() int             { return 0 }
+func (c *countWriter) offset() int64         { return c.n }
+func (c *countWriter) reserve(n int64) error { return nil }
+func (c *countWriter) finish(ok bool) error  { return nil }
@@ -0,0 +1,293 @@
+// writer_test.go
+
+// Copyright 2009-2012 David Rook. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// source can be found at http://www.github.com/hotei/go-zipfile
+
+package zipfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testEntry struct {
+	name     string
+	mode     os.FileMode
+	compress uint16
+	data     string
+}
+
+var writerEntries = []testEntry{
+	{"dir/", os.ModeDir | 0755, ZIP_STORED, ""},
+	{"dir/stored.txt", 0644, ZIP_STORED, "stored, not squeezed\n"},
+	{"dir/deflated.txt", 0600, ZIP_DEFLATED, "squeeze me squeeze me squeeze me squeeze me\n"},
+	{"dir/run.sh", 0755, ZIP_DEFLATED, "#!/bin/sh\necho hi\n"},
+	{"dir/link", os.ModeSymlink | 0777, ZIP_STORED, "run.sh"},
+	{"empty", 0644, ZIP_DEFLATED, ""},
+	{"café.txt", 0644, ZIP_DEFLATED, "utf-8 name\n"},
+}
+
+// writeTestArchive writes writerEntries with w
+func writeTestArchive(t *testing.T, w *Writer) {
+	mtime := time.Date(2012, 3, 4, 5, 6, 8, 0, time.UTC)
+	for _, te := range writerEntries {
+		h := &Header{Name: te.name, Mtime: mtime, Compress: te.compress}
+		h.SetMode(te.mode)
+		fw, err := w.Create(h)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err = fw.Write([]byte(te.data)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	w.Comment = "written by writer_test.go"
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// checkTestArchive reads writerEntries back both ways
+func checkTestArchive(t *testing.T, rz *ZipReader) {
+	local, err := rz.Headers()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	central, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(local) != len(writerEntries) || len(central) != len(writerEntries) {
+		t.Fatalf("wrote %d entries, read %d local %d central", len(writerEntries), len(local), len(central))
+	}
+	for ndx, te := range writerEntries {
+		hdr := central[ndx]
+		if hdr.Name != te.name || local[ndx].Name != te.name {
+			t.Fatalf("entry %d: got %s, want %s", ndx, hdr.Name, te.name)
+		}
+		if hdr.Mode() != te.mode {
+			t.Errorf("%s: mode %v, want %v", te.name, hdr.Mode(), te.mode)
+		}
+		if !hdr.Mtime.Equal(time.Date(2012, 3, 4, 5, 6, 8, 0, time.UTC)) {
+			t.Errorf("%s: mtime %v", te.name, hdr.Mtime)
+		}
+		for _, h := range []*Header{local[ndx], hdr} {
+			rdr, err := h.Open()
+			if err != nil {
+				t.Fatalf("%s: Unexpected error: %v", te.name, err)
+			}
+			got, _ := ioutil.ReadAll(rdr)
+			if string(got) != te.data {
+				t.Errorf("%s: got %q, want %q", te.name, got, te.data)
+			}
+		}
+	}
+}
+
+// Purpose: round trip through Writer, read back with Headers() and CentralHeaders()
+func TestWriter(t *testing.T) {
+	fmt.Printf("TestWriter start\n")
+	var buf bytes.Buffer
+	writeTestArchive(t, NewWriter(&buf))
+	rz, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	checkTestArchive(t, rz)
+	e, err := findEndCentDir(bytes.NewReader(buf.Bytes()))
+	if err != nil || e.comment != "written by writer_test.go" {
+		t.Errorf("archive comment %q, %v", e.comment, err)
+	}
+	fmt.Printf("TestWriter fini\n")
+}
+
+// Purpose: misuse is reported, not silently ignored
+func TestWriterErrors(t *testing.T) {
+	w := NewWriter(ioutil.Discard)
+	fw, err := w.Create(&Header{Name: "d/"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = fw.Write([]byte("x")); err != DirWriteError {
+		t.Errorf("write to directory: expected DirWriteError, got %v", err)
+	}
+	if _, err = w.Create(&Header{Name: "x", Compress: 99}); err != InvalidCompError {
+		t.Errorf("method 99: expected InvalidCompError, got %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = w.Create(&Header{Name: "late"}); err != WriterClosedError {
+		t.Errorf("Create after Close: expected WriterClosedError, got %v", err)
+	}
+}
+
+// Purpose: makeDosDate and makeGoDate agree, and pre-1980 times are clamped
+func TestDosDate(t *testing.T) {
+	for _, tm := range []time.Time{
+		time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2012, 12, 31, 23, 59, 58, 0, time.UTC),
+		time.Date(2107, 6, 15, 12, 30, 10, 0, time.UTC),
+	} {
+		d, dt := makeDosDate(tm)
+		if got := makeGoDate(d, dt); !got.Equal(tm) {
+			t.Errorf("%v came back as %v", tm, got)
+		}
+	}
+	d, dt := makeDosDate(time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC))
+	if got := makeGoDate(d, dt); got.Year() != MSDOS_EPOCH {
+		t.Errorf("1970 came back as %v", got)
+	}
+}
+
+// what TestDeterministic writes must hash to this, a change means archives
+// built from the same tree would no longer match earlier builds
+const deterministicSHA256 = "0afe6ac0ef7af52189adb309c99fff7db61aed25ca776877cbbca2c455f07ac9"
+
+// Purpose: a Deterministic Writer gives the same bytes whatever order the
+// entries come in and whatever their times and permissions
+func TestDeterministic(t *testing.T) {
+	fmt.Printf("TestDeterministic start\n")
+	os.Setenv("SOURCE_DATE_EPOCH", "1334000000")
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+	var sums []string
+	for pass, order := range [][]int{{0, 1, 2, 3, 4}, {4, 2, 0, 3, 1}} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.Deterministic = true
+		for _, ndx := range order {
+			te := writerEntries[ndx] // all stored, so the hash doesn't depend on compress/flate
+			h := &Header{Name: te.name, Compress: ZIP_STORED,
+				Mtime: time.Date(2012, 3, 4, 5, 6, 8+2*pass, 0, time.UTC)}
+			h.SetMode(te.mode &^ os.FileMode(pass*022))
+			fw, err := w.Create(h)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			io.WriteString(fw, te.data)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		sums = append(sums, fmt.Sprintf("%x", sha256.Sum256(buf.Bytes())))
+
+		rz, err := NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		filelist, err := rz.CentralHeaders()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for ndx, h := range filelist {
+			if ndx > 0 && filelist[ndx-1].Name > h.Name {
+				t.Errorf("%s written after %s", h.Name, filelist[ndx-1].Name)
+			}
+			if h.Mtime.Unix() != 1334000000 {
+				t.Errorf("%s: Mtime %v", h.Name, h.Mtime)
+			}
+		}
+		if filelist[1].Name != "dir/deflated.txt" || filelist[1].Mode() != 0644 {
+			t.Errorf("%s has mode %v, expected 0644", filelist[1].Name, filelist[1].Mode())
+		}
+	}
+	if sums[0] != sums[1] || sums[0] != deterministicSHA256 {
+		t.Errorf("SHA-256 %s and %s, expected %s", sums[0], sums[1], deterministicSHA256)
+	}
+
+	os.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	w := NewWriter(ioutil.Discard)
+	w.Deterministic = true
+	if _, err := w.Create(&Header{Name: "x"}); err != EpochError {
+		t.Errorf("bad SOURCE_DATE_EPOCH: expected EpochError, got %v", err)
+	}
+	fmt.Printf("TestDeterministic fini\n")
+}
+
+// Purpose: entries copied with OpenRaw and CreateRaw read back the same as
+// the originals, and the compressed bytes are identical
+func TestRawCopy(t *testing.T) {
+	fmt.Printf("TestRawCopy start\n")
+	f, err := os.Open("testdata/phpBB.zip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+	rz, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err := rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	copied := make(map[string][]byte)
+	for _, h := range filelist {
+		if !strings.HasSuffix(h.Name, ".php") {
+			continue
+		}
+		src, err := h.OpenRaw()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		raw, err := ioutil.ReadAll(src)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		copied[h.Name] = raw
+		nh := &Header{Name: h.Name, Mtime: h.Mtime, Compress: h.Compress,
+			StoredCrc32: h.StoredCrc32, Size: h.Size}
+		fw, err := w.CreateRaw(nh)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		fw.Write(raw)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(copied) == 0 {
+		t.Fatalf("no .php entries in phpBB.zip")
+	}
+
+	rz, err = NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	filelist, err = rz.CentralHeaders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(filelist) != len(copied) {
+		t.Errorf("copied %d entries, read back %d", len(copied), len(filelist))
+	}
+	for _, h := range filelist {
+		src, err := h.OpenRaw()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		raw, _ := ioutil.ReadAll(src)
+		if !bytes.Equal(raw, copied[h.Name]) {
+			t.Errorf("%s: compressed data changed", h.Name)
+		}
+		rdr, err := h.Open() // checks the CRC
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", h.Name, err)
+		}
+		if _, err = ioutil.ReadAll(rdr); err != nil {
+			t.Errorf("%s: Unexpected error: %v", h.Name, err)
+		}
+	}
+	fmt.Printf("TestRawCopy fini\n")
+}
@@ -21,7 +21,6 @@ package zipfile
 
 import (
 	"bytes"
-	"compress/flate"
 	"errors"
 	"fmt"
 	"hash/crc32"
@@ -48,6 +47,7 @@ var (
 	InvalidCompError = errors.New("Bad compression method value")
 	ShortReadError   = errors.New("short read")
 	FutureTimeError  = errors.New("file's last Mod time is in future")
+	BadDateError     = errors.New("file's last Mod date or time is out of range")
 	Slice16Error     = errors.New("sixteenBit() did not get a 16 bit arg")
 	Slice32Error     = errors.New("thirtytwoBit() did not get a 32 bit arg")
 	CRC32MatchError  = errors.New("Stored CRC32 doesn't match computed CRC32")
@@ -92,11 +92,19 @@ var (
 type ZipReader struct {
 	current_file int
 	reader       io.ReadSeeker
+	limits       Limits   // see limits.go
+	expanded     *int64   // bytes decompressed so far, shared with nested readers
+	depth        int      // how many OpenNested calls deep we are
+	volumes      *Volumes // set by NewVolumeReader, maps disk numbers to offsets
+	shift        int64    // added to directory offsets when a prefix wasn't allowed for, see Prefix()
+	password     []byte   // for encrypted entries, see SetPassword()
 }
 
 func NewReader(r io.ReadSeeker) (*ZipReader, error) {
 	x := new(ZipReader)
 	x.reader = r
+	x.limits = DefaultLimits
+	x.expanded = new(int64)
 	_, err := r.Seek(0, 0) // make sure we've got a seekable input  ? may be unnecessary ?
 	// err might not be nil on return - caller MUST test
 	return x, err
@@ -110,9 +118,20 @@ type Header struct {
 	Typeflag    byte
 	Mtime       time.Time // use 'go' version of time, not MSDOS version
 	Compress    uint16    // only one method implemented and thats flate/deflate
-	Offset      int64
+	Offset      int64     // start of the data, see DataOffset()
+	LocalOffset int64     // start of the local header
 	StoredCrc32 uint32
 	Hreader     io.ReadSeeker
+	zr          *ZipReader // limits and running totals for Open()
+	dosDate     uint16     // Mtime as stored, for CheckDate()
+	dosTime     uint16
+	// only set from the central directory, see CentralHeaders()
+	VersionMadeBy uint16 // upper byte is host system, 3 == unix
+	ExternalAttrs uint32 // host dependent, unix keeps st_mode in upper 16 bits
+	Flags         uint16 // general purpose bit flag
+	InternalAttrs uint16 // bit 0 set means the entry is probably text
+	Extra         []byte // extra field from the central directory
+	Comment       string // entry comment
 }
 
 // Unpack header based on PKWare's APPNOTE.TXT
@@ -124,9 +143,10 @@ func (h *Header) unpackLocalHeader(src []byte) error {
 		}
 		return InvalidSigError // has invalid sig and its not last file in archive
 	}
+	h.Flags = sixteenBit(src[6:8])
 	h.Compress = sixteenBit(src[8:10])
 
-	if h.Compress != ZIP_STORED && h.Compress != ZIP_DEFLATED {
+	if !knownMethod(h.Compress) {
 		return InvalidCompError
 	}
 	h.Size = int64(thirtyTwoBit(src[22:26]))
@@ -136,6 +156,7 @@ func (h *Header) unpackLocalHeader(src []byte) error {
 	pktime := sixteenBit(src[10:12])
 	pkdate := sixteenBit(src[12:14])
 	h.Mtime = makeGoDate(pkdate, pktime)
+	h.dosDate, h.dosTime = pkdate, pktime
 	if h.Mtime.After(time.Now()) {
 		fmt.Fprintf(os.Stderr, "%s: %v\n", h.Name, FutureTimeError)
 		if Paranoid {
@@ -154,6 +175,7 @@ func (h *Header) unpackLocalHeader(src []byte) error {
 // returns one header pointer for each stored file
 func (r *ZipReader) Headers() ([]*Header, error) {
 	Hdrs := make([]*Header, 0, 20)
+	r.current_file = 0
 	_, err := r.reader.Seek(0, 0)
 	if err != nil {
 		if Paranoid {
@@ -203,8 +225,10 @@ func (r *ZipReader) Next() (*Header, error) {
 		}
 	}
 	if n < LocalHdrSize {
-		fmt.Printf("Read %d bytes of header = %v %s\n", LocalHdrSize, localHdr, localHdr)
-		fmt.Printf("n(%d) < LocalHdrSize(%d)\n", n, LocalHdrSize)
+		if Verbose {
+			fmt.Printf("Read %d bytes of header = %v %s\n", LocalHdrSize, localHdr, localHdr)
+			fmt.Printf("n(%d) < LocalHdrSize(%d)\n", n, LocalHdrSize)
+		}
 		if Paranoid {
 			fmt.Printf("Read %d bytes of header = %v\n", LocalHdrSize, localHdr)
 			fatal_err(ShortReadError)
@@ -212,11 +236,30 @@ func (r *ZipReader) Next() (*Header, error) {
 			return nil, ShortReadError // BUG why unexpected - sometimes
 		}
 	}
+	if r.current_file == 0 && string(localHdr[0:4]) != ZIP_LocalHdrSig && !isSpanningSig(localHdr) {
+		// perhaps a self-extractor or script in front of the archive
+		if prefix, perr := r.Prefix(); perr == nil && prefix > 0 {
+			if _, err = r.reader.Seek(prefix, 0); err == nil {
+				_, err = io.ReadFull(r.reader, localHdr)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if r.current_file == 0 && isSpanningSig(localHdr) {
+		// split archive marker, the first real header starts right after it
+		copy(localHdr, localHdr[4:])
+		if _, err = io.ReadFull(r.reader, localHdr[LocalHdrSize-4:]); err != nil {
+			return nil, err
+		}
+	}
 	if Verbose {
 		fmt.Printf("Read %d bytes of header = %v\n", LocalHdrSize, localHdr)
 	}
 	hdr := new(Header)
 	hdr.Hreader = r.reader
+	hdr.zr = r
 	err = hdr.unpackLocalHeader(localHdr)
 	if err != nil {
 		return nil, err
@@ -225,9 +268,14 @@ func (r *ZipReader) Next() (*Header, error) {
 		// not an error, return nil to signal no more data
 		return nil, nil
 	}
+	r.current_file++
+	if err = r.countEntry(r.current_file); err != nil {
+		return nil, err
+	}
 	fileNameLen := sixteenBit(localHdr[26:28])
 	// TODO read past end of archive without seeing Central Directory ? NOT POSSIBLE ?
-	// what about multi-volume disks?  Do they have any Central Dir data?
+	// multi-volume disks keep the Central Dir on the last volume, read them
+	// with NewVolumeReader so Next() sees one stream and never stops early
 	if fileNameLen == 0 {
 		fmt.Fprintf(os.Stderr, "read past end of archive and didn't find the Central Directory")
 		if Paranoid {
@@ -247,7 +295,9 @@ func (r *ZipReader) Next() (*Header, error) {
 		}
 	}
 	if n < int(fileNameLen) {
-		fmt.Printf("n < fileNameLen\n")
+		if Verbose {
+			fmt.Printf("n < fileNameLen\n")
+		}
 		if Paranoid {
 			fatal_err(ShortReadError)
 		} else {
@@ -258,6 +308,7 @@ func (r *ZipReader) Next() (*Header, error) {
 		fmt.Printf("filename: %s \n", fname)
 	}
 	hdr.Name = string(fname)
+	hdr.Typeflag = hdr.typeflag()
 	// read extra data if present
 	if Verbose {
 		fmt.Printf("reading extra data if present\n")
@@ -274,6 +325,7 @@ func (r *ZipReader) Next() (*Header, error) {
 		}
 	}
 	hdr.Offset = currentPos
+	hdr.LocalOffset = currentPos - int64(extraFieldLen) - int64(fileNameLen) - LocalHdrSize
 	// seek past compressed/stored blob to start of next header
 	_, err = r.reader.Seek(hdr.SizeCompr, 1)
 	if err != nil {
@@ -283,6 +335,22 @@ func (r *ZipReader) Next() (*Header, error) {
 			return nil, err
 		}
 	}
+	if hdr.Flags&flagDataDesc != 0 && hdr.SizeCompr > 0 {
+		// sizes were in the local header after all (we write them there),
+		// so the descriptor after the data can be stepped over.  Its
+		// signature is optional, without one it is 12 bytes.
+		sig := make([]byte, 4)
+		if _, err = io.ReadFull(r.reader, sig); err == nil {
+			skip := int64(DataDescSize)
+			if string(sig) != ZIP_DataDescSig {
+				skip -= 4 // that was the crc
+			}
+			_, err = r.reader.Seek(skip, 1)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// NOTE: side effect is to move r.reader pointer to start of next header
 	return hdr, nil
@@ -297,12 +365,7 @@ func (hdr *Header) Dump() {
 	Mtime := hdr.Mtime.UTC()
 	//	fmt.Printf("%s: Size %d, Size Compressed %d, Type flag %d, LastMod %s, ComprMeth %d, Offset %d\n",
 	//		hdr.Name, hdr.Size, hdr.SizeCompr, hdr.Typeflag, Mtime.String(), hdr.Compress, hdr.Offset)
-	var method string
-	if hdr.Compress == 8 {
-		method = "Deflated"
-	} else {
-		method = "Stored"
-	}
+	method := MethodName(hdr.Compress)
 
 	// sec := time.SecondsToUTC(hdr.Mtime)
 	// fmt.Printf("Header time parsed to : %s\n", sec.String())
@@ -312,8 +375,57 @@ func (hdr *Header) Dump() {
 		hdr.StoredCrc32, hdr.Name)
 }
 
+// names from section 4.4.5 of APPNOTE.TXT
+var methodNames = map[uint16]string{
+	0:  "Stored",
+	1:  "Shrunk",
+	2:  "Reduced1",
+	3:  "Reduced2",
+	4:  "Reduced3",
+	5:  "Reduced4",
+	6:  "Imploded",
+	7:  "Tokenized",
+	8:  "Deflated",
+	9:  "Deflate64",
+	10: "DCLImploded",
+	12: "BZIP2",
+	14: "LZMA",
+	16: "CMPSC",
+	18: "TERSE",
+	19: "LZ77",
+	93: "Zstandard",
+	94: "MP3",
+	95: "XZ",
+	96: "JPEG",
+	97: "WavPack",
+	98: "PPMd",
+	99: "AES",
+}
+
+// MethodName is the name APPNOTE.TXT gives compression method m, or
+// "Unknown(m)" if it doesn't list one
+func MethodName(m uint16) string {
+	if name, ok := methodNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", m)
+}
+
 func (h *Header) Open() (io.Reader, error) {
-	_, err := h.Hreader.Seek(h.Offset, 0)
+	if h.Hreader == nil {
+		return nil, StreamOpenError // contents only available from the StreamReader
+	}
+	if h.Compress != ZIP_STORED && decompressor(h.Compress) == nil {
+		if Paranoid {
+			fatal_err(InvalidCompError)
+		} else {
+			return nil, InvalidCompError // not stored, expanding it as if it were would be garbage
+		}
+	}
+	off, err := h.DataOffset()
+	if err == nil {
+		_, err = h.Hreader.Seek(off, 0)
+	}
 	if err != nil {
 		if Paranoid {
 			fatal_err(err)
@@ -321,23 +433,40 @@ func (h *Header) Open() (io.Reader, error) {
 			return nil, err
 		}
 	}
-	comprData := make([]byte, h.SizeCompr)
-	n, err := h.Hreader.Read(comprData)
-	if err != nil {
+	if h.zr != nil {
+		if err = h.zr.checkClaims(h); err != nil {
+			return nil, err
+		}
+	}
+	// don't trust SizeCompr for an allocation, let the buffer grow as data arrives
+	cbuf := new(bytes.Buffer)
+	n64, err := io.CopyN(cbuf, h.Hreader, h.SizeCompr)
+	if err != nil && err != io.EOF {
 		if Paranoid {
 			fatal_err(err)
 		} else {
 			return nil, err
 		}
 	}
+	comprData := cbuf.Bytes()
+	n := int(n64)
 	if int64(n) < h.SizeCompr {
-		fmt.Printf("read(%d) which is less than stored compressed size(%d)", n, h.SizeCompr)
+		if Verbose {
+			fmt.Printf("read(%d) which is less than stored compressed size(%d)\n", n, h.SizeCompr)
+		}
 		if Paranoid {
 			fatal_err(ShortReadError)
 		} else {
 			return nil, ShortReadError
 		}
 	}
+	if h.Encrypted() {
+		plain, derr := h.decrypt(comprData)
+		if derr != nil {
+			return nil, derr // wrong password isn't a media error, even for Paranoid
+		}
+		comprData = plain
+	}
 	if Verbose {
 		fmt.Printf("Header.Open() Read in %d bytes of compressed (deflated) data\n", n)
 		// prints out filename etc so we can later validate expanded data is appropriate
@@ -345,7 +474,14 @@ func (h *Header) Open() (io.Reader, error) {
 	}
 	// got it as comprData in RAM, now need to expand it
 	in := bytes.NewBuffer(comprData) // fill new buffer with compressed data
-	inpt := flate.NewReader(in)      // attach a reader to the buffer
+	var inpt io.Reader = in
+	if dcomp := decompressor(h.Compress); dcomp != nil {
+		inpt = dcomp(in) // attach a reader to the buffer
+	}
+	if h.zr != nil {
+		inpt = &limitReader{rdr: inpt, limits: &h.zr.limits, expanded: h.zr.expanded,
+			name: h.Name, comprSize: &h.SizeCompr}
+	}
 	if err != nil {
 		if Paranoid {
 			fatal_err(err)
@@ -368,6 +504,9 @@ func (h *Header) Open() (io.Reader, error) {
 	b := new(bytes.Buffer) // create a new buffer with io methods
 	var n2 int64
 	n2, err = io.Copy(b, inpt) // now fill buffer from compressed data using inpt
+	if _, ok := err.(*LimitError); ok {
+		return nil, err // hostile archive, Paranoid or not it's the caller's call
+	}
 	if err != nil {
 		if Paranoid {
 			fatal_err(err)
@@ -376,20 +515,22 @@ func (h *Header) Open() (io.Reader, error) {
 		}
 	}
 	if n2 < h.Size {
-		fmt.Printf("Actually copied %d, expected to copy %d\n", n, h.Size)
+		if Verbose {
+			fmt.Printf("Actually copied %d, expected to copy %d\n", n, h.Size)
+		}
 		if Paranoid {
 			fatal_err(ShortReadError)
 		} else {
 			return nil, ShortReadError
 		}
 	}
-	// TODO this feels like an extra step but not sure how to shorten it yet
-	// problem is we can't run ChecksumIEEE on Buffer, it requires []byte arg
-	// update: Russ Cox provided advice on method of attack, still need to implement
-	expdData := make([]byte, h.Size) // make the expanded buffer into a byte array
-	n, err = b.Read(expdData)        // copy buffer into expdData
+	// use the buffer's own bytes rather than allocating h.Size again
+	expdData := b.Bytes()
+	n = len(expdData)
 	if int64(n) < h.Size {
-		fmt.Printf("copied %d, expected %d\n", n, h.Size)
+		if Verbose {
+			fmt.Printf("copied %d, expected %d\n", n, h.Size)
+		}
 		if Paranoid {
 			fatal_err(ShortReadError)
 		} else {
@@ -424,6 +565,25 @@ func (h *Header) Open() (io.Reader, error) {
 	return bufReader, nil // who closes bufReader and how?
 }
 
+// OpenRaw returns a reader for the entry's data as it is stored, compressed
+// and perhaps encrypted, SizeCompr bytes from DataOffset().  Nothing is
+// checked, Compress, StoredCrc32 and Size say what the data is.  Give the
+// header and the data to Writer.CreateRaw to copy an entry to another archive
+// without decompressing it.
+func (h *Header) OpenRaw() (io.Reader, error) {
+	if h.Hreader == nil {
+		return nil, StreamOpenError
+	}
+	off, err := h.DataOffset()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = h.Hreader.Seek(off, 0); err != nil {
+		return nil, err
+	}
+	return io.LimitReader(h.Hreader, h.SizeCompr), nil
+}
+
 //	convert PKware date, time uint16s into seconds since Unix Epoch
 func makeGoDate(d, t uint16) time.Time {
 	var year, month, day uint16
@@ -437,7 +597,7 @@ func makeGoDate(d, t uint16) time.Time {
 	var hour, minute, second uint16
 	hour = t & 0xf800
 	hour >>= 11
-	minute = t & 0x01e0
+	minute = t & 0x07e0
 	minute >>= 5
 	second = (t & 0x001f) * 2
 	second = second
@@ -456,30 +616,11 @@ func makeGoDate(d, t uint16) time.Time {
 		fmt.Printf("year(%d) month(%d) day(%d) \n", year, month, day)
 		fmt.Printf("hour(%d) minute(%d) second(%d)\n", hour, minute, second)
 	}
-	// TODO this checking is approximate for now, daysinmonth not checked fully
 	// TODO we wont know file name at this point unless Verbose is also true
 	//  ? is that a problem or not ?
 	if Paranoid {
-		badDate := false
-		// no such thing as a bad year as 0..127 are valid
-		// and represent 1980 thru 2107
 		// if a file's Mtime is in the future Paranoid will it catch later
-		if !inRangeInt(1, int(month), 12) {
-			badDate = true
-		}
-		if !inRangeInt(1, int(day), 31) {
-			badDate = true
-		}
-		if !inRangeInt(0, int(hour), 23) {
-			badDate = true
-		}
-		if !inRangeInt(0, int(minute), 59) {
-			badDate = true
-		}
-		if !inRangeInt(0, int(second), 59) {
-			badDate = true
-		}
-		if badDate {
+		if !dosDateOK(d, t) {
 			fmt.Fprintf(os.Stderr, "Encountered bad Mod Date/Time: \n")
 			fmt.Fprintf(os.Stderr, "year(%d) month(%d) day(%d) \n", year, month, day)
 			fmt.Fprintf(os.Stderr, "hour(%d) minute(%d) second(%d)\n", hour, minute, second)
@@ -488,6 +629,37 @@ func makeGoDate(d, t uint16) time.Time {
 	return ft
 }
 
+// dosDateOK is false if the MSDOS date or time has a field out of range,
+// makeGoDate would quietly roll those over into the next month or day.
+// There's no such thing as a bad year, 0..127 are 1980 thru 2107.
+func dosDateOK(d, t uint16) bool {
+	year := int(d>>9) + MSDOS_EPOCH
+	month := int(d >> 5 & 0x0f)
+	day := int(d & 0x1f)
+	if !inRangeInt(1, month, 12) || !inRangeInt(1, day, 31) {
+		return false
+	}
+	// day 0 of the following month is the last day of this one
+	if day > time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day() {
+		return false
+	}
+	return inRangeInt(0, int(t>>11), 23) && inRangeInt(0, int(t>>5&0x3f), 59) &&
+		inRangeInt(0, int(t&0x1f)*2, 59)
+}
+
+// CheckDate reports a modification date that is impossible (BadDateError)
+// or in the future (FutureTimeError).  Media errors and buggy archivers both
+// produce these, the rest of the entry may be fine.
+func (h *Header) CheckDate() error {
+	if !dosDateOK(h.dosDate, h.dosTime) {
+		return BadDateError
+	}
+	if h.Mtime.After(time.Now()) {
+		return FutureTimeError
+	}
+	return nil
+}
+
 // true if b is between a and c, order not important
 // 1,3,5 => true
 // 5,3,1 => true